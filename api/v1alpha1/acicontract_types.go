@@ -0,0 +1,173 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ContractScope is where on the APIC the contract applies.
+// +kubebuilder:validation:Enum=context;tenant;global;application-profile
+type ContractScope string
+
+const (
+	// ContractScopeVrf limits the contract to the EPGs in one VRF.
+	ContractScopeVrf ContractScope = "context"
+	// ContractScopeTenant limits the contract to the EPGs in one tenant.
+	ContractScopeTenant ContractScope = "tenant"
+	// ContractScopeGlobal allows the contract between all EPGs.
+	ContractScopeGlobal ContractScope = "global"
+	// ContractScopeApplicationProfile limits the contract to the EPGs in one
+	// application profile.
+	ContractScopeApplicationProfile ContractScope = "application-profile"
+)
+
+// FilterProtocol is the IP protocol matched by a filter entry.
+// +kubebuilder:validation:Enum=tcp;udp;icmp;unspecified
+type FilterProtocol string
+
+// AciContractSpec defines the desired state of AciContract
+type AciContractSpec struct {
+	// ContractName is the name of the contract on the APIC, the name of the
+	// AciContract if empty. Epgconfs refer to the contract by this name.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	ContractName string `json:"contractName,omitempty"`
+
+	// Tenant is the tenant of the contract, the tenant of the ACI CNI if empty.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// Scope is where the contract applies.
+	// +kubebuilder:default=context
+	// +optional
+	Scope ContractScope `json:"scope,omitempty"`
+
+	// Subjects are the subjects of the contract.
+	// +kubebuilder:validation:MinItems=1
+	Subjects []ContractSubject `json:"subjects"`
+}
+
+// ContractSubject groups the filters of a contract.
+type ContractSubject struct {
+	// Name is the name of the subject.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	Name string `json:"name"`
+
+	// ReverseFilterPorts also allows the return traffic of the filters.
+	// +kubebuilder:default=true
+	// +optional
+	ReverseFilterPorts *bool `json:"reverseFilterPorts,omitempty"`
+
+	// Filters are the traffic the subject allows.
+	// +kubebuilder:validation:MinItems=1
+	Filters []ContractFilter `json:"filters"`
+}
+
+// ContractFilter is a filter of a contract subject.
+type ContractFilter struct {
+	// Name is the name of the filter, the filter on the APIC is named after
+	// the contract and this name.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	Name string `json:"name"`
+
+	// Entries are the traffic matched by the filter.
+	// +kubebuilder:validation:MinItems=1
+	Entries []FilterEntry `json:"entries"`
+}
+
+// FilterEntry matches IP traffic by protocol and destination port.
+// +kubebuilder:validation:XValidation:rule="!has(self.endPort) || (has(self.port) && self.endPort >= self.port)",message="endPort requires port and must not be lower than port"
+type FilterEntry struct {
+	// Name is the name of the entry.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	Name string `json:"name"`
+
+	// Protocol is the IP protocol of the traffic.
+	// +kubebuilder:default=tcp
+	// +optional
+	Protocol FilterProtocol `json:"protocol,omitempty"`
+
+	// Port is the destination port, or the first port of a range with
+	// endPort. All ports if empty.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// EndPort is the last destination port of a range starting at port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	EndPort int32 `json:"endPort,omitempty"`
+}
+
+// AciContractStatus defines the observed state of AciContract
+type AciContractStatus struct {
+	// ObservedGeneration is the generation of the AciContract that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ContractDn is the distinguished name of the contract on the APIC.
+	// +optional
+	ContractDn string `json:"contractDn,omitempty"`
+
+	// Filters are the names of the filters created on the APIC for the
+	// contract.
+	// +optional
+	Filters []string `json:"filters,omitempty"`
+
+	// Conditions describe the outcome of the reconciliation.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Contract",type=string,JSONPath=`.status.contractDn`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// AciContract is the Schema for the acicontracts API
+type AciContract struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AciContractSpec   `json:"spec,omitempty"`
+	Status AciContractStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AciContractList contains a list of AciContract
+type AciContractList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AciContract `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AciContract{}, &AciContractList{})
+}
@@ -23,24 +23,247 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// ContractMode controls how the contracts listed in an Epgconf are combined with
+// the operator wide defaults from the default-epg-contracts ConfigMap.
+// +kubebuilder:validation:Enum=Merge;Replace
+type ContractMode string
+
+const (
+	// ContractModeMerge adds the contracts in the spec to the default contracts.
+	ContractModeMerge ContractMode = "Merge"
+	// ContractModeReplace uses only the contracts in the spec and ignores the defaults.
+	ContractModeReplace ContractMode = "Replace"
+)
+
+// AdoptionPolicy controls what the operator does when the EPG already exists on
+// the APIC and was not created by it.
+// +kubebuilder:validation:Enum=Create;Adopt;Fail
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyCreate creates the EPG if it is missing. An existing EPG
+	// is used but not owned, the operator only configures its contracts and
+	// never deletes it.
+	AdoptionPolicyCreate AdoptionPolicy = "Create"
+	// AdoptionPolicyAdopt takes ownership of an existing EPG, it is then
+	// managed and deleted like an EPG the operator created.
+	AdoptionPolicyAdopt AdoptionPolicy = "Adopt"
+	// AdoptionPolicyFail fails the Epgconf if the EPG exists and was not
+	// created by the operator.
+	AdoptionPolicyFail AdoptionPolicy = "Fail"
+)
+
+// DeletionPolicy controls what happens to the EPG on the APIC when the Epgconf
+// is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain;OrphanContractsOnly
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the EPG.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the EPG and its contracts.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyOrphanContractsOnly keeps the EPG but removes the
+	// contracts added by the operator.
+	DeletionPolicyOrphanContractsOnly DeletionPolicy = "OrphanContractsOnly"
+)
+
 // EpgconfSpec defines the desired state of Epgconf
 type EpgconfSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// EpgName overrides the EPG name rendered from the operator naming template.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	EpgName string `json:"epgName,omitempty"`
+
+	// Tenant overrides the tenant of the ACI CNI for the EPG. It must be
+	// allowed by the operator.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// ApplicationProfile overrides the application profile of the ACI CNI for
+	// the EPG. It must be allowed by the operator.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	ApplicationProfile string `json:"applicationProfile,omitempty"`
+
+	// BridgeDomain overrides the pod bridge domain of the ACI CNI for the EPG,
+	// e.g. to isolate the namespace on layer 2. It must be allowed by the
+	// operator.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	BridgeDomain string `json:"bridgeDomain,omitempty"`
+
+	// CreateMissingParents creates the application profile and the bridge
+	// domain of the EPG if they do not exist on the APIC.
+	// +optional
+	CreateMissingParents bool `json:"createMissingParents,omitempty"`
+
+	// Vrf is the VRF of a bridge domain created with createMissingParents,
+	// the VRF of the ACI CNI if empty.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	Vrf string `json:"vrf,omitempty"`
+
+	// Subnets are the gateway addresses added to the bridge domain with
+	// createMissingParents, e.g. 10.10.0.1/24.
+	// +optional
+	Subnets []string `json:"subnets,omitempty"`
+
+	// Shared allows Epgconfs in other namespaces to use the same EPG, set
+	// together with epgName. The contracts of all of them are combined and the
+	// EPG is deleted with the last Epgconf using it.
+	// +optional
+	Shared bool `json:"shared,omitempty"`
+
+	// ProvidedContracts is a list of contract names the namespace EPG provides.
+	// +optional
+	ProvidedContracts []string `json:"providedContracts,omitempty"`
+
+	// ConsumedContracts is a list of contract names the namespace EPG consumes.
+	// +optional
+	ConsumedContracts []string `json:"consumedContracts,omitempty"`
+
+	// ContractMode decides if the contracts above are merged with the default
+	// contracts or replace them.
+	// +kubebuilder:default=Merge
+	// +optional
+	ContractMode ContractMode `json:"contractMode,omitempty"`
+
+	// AdoptionPolicy decides what happens when the EPG already exists on the
+	// APIC and was not created by the operator.
+	// +kubebuilder:default=Create
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
 
-	// Foo is an example field of Epgconf. Edit epgconf_types.go to remove/update
-	// Foo string `json:"foo,omitempty"`
+	// DeletionPolicy decides what happens to the EPG when the Epgconf is
+	// deleted, the operator default is used if empty. A retained EPG is
+	// tagged as orphaned on the APIC and the namespace keeps its annotation.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Esg places the EPG into an endpoint security group. The contracts are
+	// then applied to the ESG instead of the EPG.
+	// +optional
+	Esg *EsgSpec `json:"esg,omitempty"`
+}
+
+// EsgSpec selects the endpoint security group of the EPG.
+type EsgSpec struct {
+	// Name is the name of the ESG in the application profile of the EPG.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	Name string `json:"name"`
+
+	// Create creates the ESG if it does not exist on the APIC. It is deleted
+	// with the last Epgconf using it.
+	// +optional
+	Create bool `json:"create,omitempty"`
+
+	// Vrf is the VRF of a created ESG, the VRF of the ACI CNI if empty.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	Vrf string `json:"vrf,omitempty"`
+
+	// TagSelectors add the endpoints carrying one of the tags to the ESG.
+	// +optional
+	TagSelectors []TagSelector `json:"tagSelectors,omitempty"`
+}
+
+// TagSelector matches endpoints by a tag key and value.
+type TagSelector struct {
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+	// +kubebuilder:validation:MinLength=1
+	Value string `json:"value"`
 }
 
+// Condition types reported on an Epgconf, one for each step of the reconciliation.
+const (
+	// ConditionEpgCreated tells if the EPG exists on the APIC.
+	ConditionEpgCreated = "EpgCreated"
+	// ConditionBridgeDomainBound tells if the EPG is bound to its bridge domain.
+	ConditionBridgeDomainBound = "BridgeDomainBound"
+	// ConditionVmmDomainBound tells if the EPG is attached to the VMM domain.
+	ConditionVmmDomainBound = "VmmDomainBound"
+	// ConditionContractsSynced tells if the contracts on the EPG match the desired contracts.
+	ConditionContractsSynced = "ContractsSynced"
+	// ConditionNamespaceAnnotated tells if the namespace carries the EPG annotation.
+	ConditionNamespaceAnnotated = "NamespaceAnnotated"
+	// ConditionEsgBound tells if the EPG is selected by its ESG, only set
+	// with spec.esg.
+	ConditionEsgBound = "EsgBound"
+	// ConditionDriftDetected tells if the EPG on the APIC differs from the
+	// desired state. Drift the reconcile repaired is False with the reason
+	// DriftRepaired and names the drift in the message.
+	ConditionDriftDetected = "DriftDetected"
+	// ConditionContractMissing tells if contracts of the Epgconf do not
+	// exist in the tenant or in common, the message names them.
+	ConditionContractMissing = "ContractMissing"
+	// ConditionApicUnavailable tells if the operator cannot reach the APIC,
+	// the Epgconf is reconciled again once it is reachable.
+	ConditionApicUnavailable = "ApicUnavailable"
+	// ConditionReady is true when all other conditions are true.
+	ConditionReady = "Ready"
+)
+
 // EpgconfStatus defines the observed state of Epgconf
 type EpgconfStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// State is a short summary of the reconciliation, either Ready or Failed.
 	State string `json:"state"`
+
+	// ObservedGeneration is the generation of the Epgconf that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// EpgName is the name of the EPG on the APIC.
+	// +optional
+	EpgName string `json:"epgName,omitempty"`
+
+	// EpgDn is the distinguished name of the EPG on the APIC.
+	// +optional
+	EpgDn string `json:"epgDn,omitempty"`
+
+	// EpgOwned tells if the EPG is owned by the operator and will be deleted
+	// with the Epgconf.
+	// +optional
+	EpgOwned bool `json:"epgOwned,omitempty"`
+
+	// EsgDn is the distinguished name of the ESG selecting the EPG.
+	// +optional
+	EsgDn string `json:"esgDn,omitempty"`
+
+	// EsgOwned tells if the ESG was created by the operator and will be
+	// deleted with the last Epgconf using it.
+	// +optional
+	EsgOwned bool `json:"esgOwned,omitempty"`
+
+	// SharedWith lists the other namespaces using the EPG.
+	// +optional
+	SharedWith []string `json:"sharedWith,omitempty"`
+
+	// LastApicError is the message of the last error returned by the APIC.
+	// +optional
+	LastApicError string `json:"lastApicError,omitempty"`
+
+	// Conditions describe the outcome of each reconciliation step.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="EPG",type=string,JSONPath=`.status.epgDn`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Epgconf is the Schema for the epgconfs API
 type Epgconf struct {
@@ -0,0 +1,139 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EpgOperatorConfigSpec defines the desired state of EpgOperatorConfig. Empty
+// fields are taken from aci-containers-config and default-epg-contracts in
+// aci-containers-system when they exist.
+type EpgOperatorConfigSpec struct {
+	// ApicHosts are the members of the APIC cluster. Changing them needs a
+	// restart of the operator.
+	// +optional
+	ApicHosts []string `json:"apicHosts,omitempty"`
+
+	// ApicUsername is the APIC user of the operator, unless the credentials
+	// Secret has one. Changing it needs a restart of the operator.
+	// +optional
+	ApicUsername string `json:"apicUsername,omitempty"`
+
+	// CredentialsSecret is the Secret with the APIC credentials, instead of
+	// the --apic-credentials-secret flag. Changing it needs a restart of the
+	// operator.
+	// +optional
+	CredentialsSecret *corev1.SecretReference `json:"credentialsSecret,omitempty"`
+
+	// Tenant is the tenant of the EPGs.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// ApplicationProfile is the application profile of the EPGs.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	ApplicationProfile string `json:"applicationProfile,omitempty"`
+
+	// BridgeDomain is the bridge domain the EPGs are bound to.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	BridgeDomain string `json:"bridgeDomain,omitempty"`
+
+	// Vrf is the VRF of the bridge domains and ESGs the operator creates.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	Vrf string `json:"vrf,omitempty"`
+
+	// VmmDomain is the VMM domain the EPGs are attached to.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.:-]+$`
+	// +optional
+	VmmDomain string `json:"vmmDomain,omitempty"`
+
+	// VmmDomainType is the type of the VMM domain.
+	// +kubebuilder:validation:Enum=Kubernetes;OpenShift
+	// +optional
+	VmmDomainType string `json:"vmmDomainType,omitempty"`
+
+	// ProvidedContracts are provided by every EPG, they replace the provided
+	// contracts of default-epg-contracts when set.
+	// +optional
+	ProvidedContracts []string `json:"providedContracts,omitempty"`
+
+	// ConsumedContracts are consumed by every EPG, they replace the consumed
+	// contracts of default-epg-contracts when set.
+	// +optional
+	ConsumedContracts []string `json:"consumedContracts,omitempty"`
+
+	// EpgNameTemplate is the Go template of the EPG names, instead of the
+	// --epg-name-template flag.
+	// +optional
+	EpgNameTemplate string `json:"epgNameTemplate,omitempty"`
+}
+
+// EpgOperatorConfigStatus defines the observed state of EpgOperatorConfig
+type EpgOperatorConfigStatus struct {
+	// ObservedGeneration is the generation of the EpgOperatorConfig that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// AciContainersConfig tells if the defaults were read from
+	// aci-containers-config.
+	// +optional
+	AciContainersConfig bool `json:"aciContainersConfig,omitempty"`
+
+	// Conditions describe the outcome of the validation.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// EpgOperatorConfig is the Schema for the epgoperatorconfigs API
+type EpgOperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EpgOperatorConfigSpec   `json:"spec,omitempty"`
+	Status EpgOperatorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// EpgOperatorConfigList contains a list of EpgOperatorConfig
+type EpgOperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EpgOperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EpgOperatorConfig{}, &EpgOperatorConfigList{})
+}
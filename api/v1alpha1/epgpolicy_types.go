@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyLabel is set on the Epgconfs created by an EpgPolicy to the name of
+// the policy.
+const PolicyLabel = "epg.custom.aci/policy"
+
+// EpgPolicySpec defines the desired state of EpgPolicy
+type EpgPolicySpec struct {
+	// NamespaceSelector selects the namespaces that get an Epgconf.
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// Template is the spec of the Epgconfs created in the selected namespaces.
+	// +optional
+	Template EpgconfSpec `json:"template,omitempty"`
+}
+
+// EpgPolicyStatus defines the observed state of EpgPolicy
+type EpgPolicyStatus struct {
+	// ObservedGeneration is the generation of the EpgPolicy that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Namespaces are the selected namespaces with an Epgconf from this policy.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Conflicts are the selected namespaces that already have an Epgconf
+	// not created by this policy.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+
+	// Conditions describe the outcome of the reconciliation.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 63",message="name must be no more than 63 characters, it is used as the value of the policy label"
+
+// EpgPolicy is the Schema for the epgpolicies API
+type EpgPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EpgPolicySpec   `json:"spec,omitempty"`
+	Status EpgPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// EpgPolicyList contains a list of EpgPolicy
+type EpgPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EpgPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EpgPolicy{}, &EpgPolicyList{})
+}
@@ -17,26 +17,26 @@ limitations under the License.
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/remotecommand"
 
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -46,8 +46,9 @@ import (
 
 	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
 	"github.com/4ndersson/epg-config-operator/internal/controller"
+	webhookv1alpha1 "github.com/4ndersson/epg-config-operator/internal/webhook/v1alpha1"
 	"github.com/4ndersson/epg-config-operator/pkg/aci"
-	"github.com/tidwall/gjson"
+	"github.com/samber/lo"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -63,113 +64,119 @@ func init() {
 	// +kubebuilder:scaffold:scheme
 }
 
-func getStartupConfiguration(c client.Client, cs *kubernetes.Clientset, co *rest.Config) (controller.CniConfig, error) {
-	configConfigMap := &corev1.ConfigMapList{}
-	err := c.List(context.TODO(),
-		configConfigMap,
-		client.InNamespace("aci-containers-system"),
-		client.MatchingFields{"metadata.name": "aci-containers-config"})
+// getStartupConfiguration reads the EpgOperatorConfig, the ACI CNI config and
+// the APIC credentials. The credentials come from the Secret of the
+// EpgOperatorConfig or the flag, or from the APIC_PASSWORD environment
+// variable when no Secret is configured. The Secret used is returned. A
+// certificate name given as flag overrides the one of aci-containers-config.
+func getStartupConfiguration(c client.Reader, configName string, secret types.NamespacedName, keys controller.CredentialKeys, certificateName string) (controller.CniConfig, aci.Credentials, types.NamespacedName, error) {
+	cniConfig, operatorConfig, found, err := controller.LoadCniConfig(context.TODO(), c, configName)
 	if err != nil {
-		return controller.CniConfig{}, err
+		return controller.CniConfig{}, aci.Credentials{}, secret, err
 	}
-
-	contractConfigMap := &corev1.ConfigMapList{}
-	err = c.List(context.TODO(),
-		contractConfigMap,
-		client.InNamespace("aci-containers-system"),
-		client.MatchingFields{"metadata.name": "default-epg-contracts"})
-	if err != nil {
-		return controller.CniConfig{}, err
+	if !found && operatorConfig == nil {
+		return controller.CniConfig{}, aci.Credentials{}, secret, fmt.Errorf("neither EpgOperatorConfig %s nor ConfigMap %s/%s exist",
+			configName, controller.AciContainersNamespace, controller.AciContainersConfigName)
+	}
+	if operatorConfig != nil && operatorConfig.Spec.CredentialsSecret != nil {
+		secret = types.NamespacedName{
+			Namespace: lo.Ternary(operatorConfig.Spec.CredentialsSecret.Namespace != "", operatorConfig.Spec.CredentialsSecret.Namespace, controller.AciContainersNamespace),
+			Name:      operatorConfig.Spec.CredentialsSecret.Name,
+		}
 	}
 
-	podList := &corev1.PodList{}
-	err = c.List(context.TODO(), podList, client.InNamespace("aci-containers-system"))
-
-	if err != nil {
-		return controller.CniConfig{}, err
+	if certificateName != "" {
+		cniConfig.ApicCertificateName = certificateName
 	}
 
-	controllerPod := ""
-	for _, pod := range podList.Items {
-		if strings.Contains(pod.Name, "controller") {
-			controllerPod = pod.Name
-			break
+	credentials := apicCredentialDefaults(cniConfig)
+	credentials.Password = os.Getenv("APIC_PASSWORD")
+	if secret.Name != "" {
+		credentialsSecret := &corev1.Secret{}
+		err = c.Get(context.TODO(), secret, credentialsSecret)
+		if err != nil {
+			return controller.CniConfig{}, aci.Credentials{}, secret, fmt.Errorf("could not read APIC credentials: %w", err)
 		}
+		credentials, err = controller.CredentialsFromSecret(credentialsSecret, keys, apicCredentialDefaults(cniConfig))
+		if err != nil {
+			return controller.CniConfig{}, aci.Credentials{}, secret, err
+		}
+	} else if credentials.Password == "" {
+		return controller.CniConfig{}, aci.Credentials{}, secret, fmt.Errorf("no APIC credentials, set --apic-credentials-secret or APIC_PASSWORD")
 	}
 
-	var cert, password string
-
-	if controllerPod != "" {
-		req := cs.CoreV1().RESTClient().
-			Post().
-			Resource("pods").
-			Name(controllerPod).
-			Namespace("aci-containers-system").
-			SubResource("exec").
-			VersionedParams(&corev1.PodExecOptions{
-				Command: []string{"/bin/sh", "-c",
-					fmt.Sprintf("cat %s", gjson.Get(configConfigMap.Items[0].Data["controller-config"],
-						"apic-private-key-path").String())},
-				Stdin:  false,
-				Stdout: true,
-				Stderr: true,
-			}, runtime.NewParameterCodec(scheme))
-
-		exec, err := remotecommand.NewSPDYExecutor(co, "POST", req.URL())
+	cniConfig.ApicPassword = credentials.Password
+	cniConfig.ApicPrivateKey = credentials.PrivateKey
+	return cniConfig, credentials, secret, nil
+}
+
+// getApicCABundle reads the CA bundle of the APIC from the key of the
+// ConfigMap or the Secret, at most one of them may be given.
+func getApicCABundle(c client.Reader, configMap, secret types.NamespacedName, key string) ([]byte, error) {
+	switch {
+	case configMap.Name != "" && secret.Name != "":
+		return nil, fmt.Errorf("the APIC CA bundle can be read from a ConfigMap or a Secret, not both")
+	case configMap.Name != "":
+		caConfigMap := &corev1.ConfigMap{}
+		err := c.Get(context.TODO(), configMap, caConfigMap)
 		if err != nil {
-			return controller.CniConfig{}, err
+			return nil, err
 		}
-
-		var stdout, stderr bytes.Buffer
-		err = exec.StreamWithContext(context.TODO(), remotecommand.StreamOptions{
-			Stdout: &stdout,
-			Stderr: &stderr,
-		})
+		if caConfigMap.Data[key] == "" {
+			return nil, fmt.Errorf("%s is missing in ConfigMap %s", key, configMap)
+		}
+		return []byte(caConfigMap.Data[key]), nil
+	case secret.Name != "":
+		caSecret := &corev1.Secret{}
+		err := c.Get(context.TODO(), secret, caSecret)
 		if err != nil {
-			return controller.CniConfig{}, err
+			return nil, err
 		}
-		cert = stdout.String()
-	} else {
-		password = os.Getenv("APIC_PASSWORD")
+		if len(caSecret.Data[key]) == 0 {
+			return nil, fmt.Errorf("%s is missing in Secret %s", key, secret)
+		}
+		return caSecret.Data[key], nil
+	default:
+		return nil, nil
 	}
+}
 
-	if cert == "" && password == "" {
-		return controller.CniConfig{}, fmt.Errorf("could not find cert or password")
-	}
+// apicCredentialDefaults returns the username and certificate name used when
+// the Secret has none.
+func apicCredentialDefaults(cniConfig controller.CniConfig) aci.Credentials {
+	return aci.Credentials{Username: cniConfig.ApicUsername, CertificateName: cniConfig.ApicCertificateName}
+}
 
-	providedContracts := gjson.Get(contractConfigMap.Items[0].Data["provided"], "@this").Array()
-	providedContractsList := make([]string, len(providedContracts))
-	for i, item := range providedContracts {
-		providedContractsList[i] = item.String()
+// parseObjectRef parses a Secret or ConfigMap given as namespace/name, a bare
+// name is in the namespace of the ACI CNI.
+func parseObjectRef(value string) (types.NamespacedName, error) {
+	if value == "" {
+		return types.NamespacedName{}, nil
 	}
-
-	consumedContracts := gjson.Get(contractConfigMap.Items[0].Data["consumed"], "@this").Array()
-	consumedContractsList := make([]string, len(consumedContracts))
-	for i, item := range consumedContracts {
-		consumedContractsList[i] = item.String()
+	parts := strings.Split(value, "/")
+	switch {
+	case len(parts) == 1:
+		return types.NamespacedName{Namespace: controller.AciContainersNamespace, Name: parts[0]}, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+	default:
+		return types.NamespacedName{}, fmt.Errorf("invalid reference %q, expected namespace/name", value)
 	}
+}
 
-	return controller.CniConfig{
-		ApicIp:         gjson.Get(configConfigMap.Items[0].Data["controller-config"], "apic-hosts.0").String(),
-		ApicUsername:   gjson.Get(configConfigMap.Items[0].Data["controller-config"], "apic-username").String(),
-		ApicPassword:   password,
-		ApicPrivateKey: cert,
-		KeyPath:        gjson.Get(configConfigMap.Items[0].Data["controller-config"], "apic-private-key-path").String(),
-		Tenant:         gjson.Get(configConfigMap.Items[0].Data["controller-config"], "aci-policy-tenant").String(),
-		BridgeDomain: strings.Replace(strings.Split(gjson.Get(configConfigMap.Items[0].Data["controller-config"],
-			"aci-podbd-dn").String(), "/")[2], "BD-", "", -1),
-		VmmDomain:          gjson.Get(configConfigMap.Items[0].Data["controller-config"], "aci-vmm-domain").String(),
-		VmmDomainType:      gjson.Get(configConfigMap.Items[0].Data["controller-config"], "aci-vmm-type").String(),
-		ApplicationProfile: gjson.Get(configConfigMap.Items[0].Data["controller-config"], "app-profile").String(),
-		ProvidedContracts:  providedContractsList,
-		ConsumedContracts:  consumedContractsList,
-	}, nil
+// splitList splits a comma separated flag value, ignoring empty items.
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
 
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;patch;watch
-// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
-// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
 
 func main() {
 	var metricsAddr string
@@ -177,6 +184,24 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var apicResyncInterval time.Duration
+	var apicCheckInterval time.Duration
+	var epgNameTemplate string
+	var clusterName string
+	var allowedTenants string
+	var allowedApplicationProfiles string
+	var allowedBridgeDomains string
+	var allowedVmmDomains string
+	var defaultDeletionPolicy string
+	var operatorConfigName string
+	var apicCredentialsSecret string
+	var apicCertificateName string
+	var apicCAConfigMap string
+	var apicCASecret string
+	var apicCAKey string
+	var apicServerName string
+	var apicInsecureSkipVerify bool
+	credentialKeys := controller.DefaultCredentialKeys
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -188,6 +213,53 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&apicResyncInterval, "apic-resync-interval", 10*time.Minute,
+		"How often each Epgconf is compared with the APIC to detect and repair drift. Set to 0 to disable.")
+	flag.DurationVar(&apicCheckInterval, "apic-check-interval", controller.DefaultApicCheckInterval,
+		"How often the connection to the APIC is checked. An unreachable APIC is checked with backoff up to this interval.")
+	flag.StringVar(&epgNameTemplate, "epg-name-template", controller.DefaultEpgNameTemplate,
+		"Go template for the EPG name of a namespace. Available fields are .Namespace, .Name and .Cluster.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"Cluster name used by the EPG name template. Defaults to the aci-prefix of the ACI CNI.")
+	flag.StringVar(&allowedTenants, "allowed-tenants", "",
+		"Comma separated list of tenants Epgconfs may use. Empty allows all.")
+	flag.StringVar(&allowedApplicationProfiles, "allowed-application-profiles", "",
+		"Comma separated list of application profiles Epgconfs may use. Empty allows all.")
+	flag.StringVar(&allowedBridgeDomains, "allowed-bridge-domains", "",
+		"Comma separated list of bridge domains Epgconfs may use. Empty allows all.")
+	flag.StringVar(&allowedVmmDomains, "allowed-vmm-domains", "",
+		"Comma separated list of VMM domains the operator config may use. Empty allows all.")
+	flag.StringVar(&defaultDeletionPolicy, "default-deletion-policy", string(epgv1alpha1.DeletionPolicyDelete),
+		"Deletion policy of Epgconfs without spec.deletionPolicy. One of Delete, Retain or OrphanContractsOnly.")
+	flag.StringVar(&operatorConfigName, "operator-config", controller.DefaultOperatorConfigName,
+		"Name of the EpgOperatorConfig, its fields override aci-containers-config and default-epg-contracts.")
+	flag.StringVar(&apicCredentialsSecret, "apic-credentials-secret", "",
+		"Secret with the APIC credentials as namespace/name, a bare name is in the aci-containers-system namespace. "+
+			"Without it the password is read from APIC_PASSWORD.")
+	flag.StringVar(&credentialKeys.Username, "apic-credentials-username-key", credentialKeys.Username,
+		"Key of the APIC username in the credentials Secret, the username of the ACI CNI is used when it is missing.")
+	flag.StringVar(&credentialKeys.Password, "apic-credentials-password-key", credentialKeys.Password,
+		"Key of the APIC password in the credentials Secret.")
+	flag.StringVar(&credentialKeys.PrivateKey, "apic-credentials-private-key-key", credentialKeys.PrivateKey,
+		"Key of the private key of the APIC user certificate in the credentials Secret, used instead of the password.")
+	flag.StringVar(&credentialKeys.Certificate, "apic-credentials-certificate-key", credentialKeys.Certificate,
+		"Key of the APIC user certificate in the credentials Secret, checked against the private key when present.")
+	flag.StringVar(&credentialKeys.CertificateName, "apic-credentials-certificate-name-key", credentialKeys.CertificateName,
+		"Key of the name of the certificate on the APIC user in the credentials Secret.")
+	flag.StringVar(&apicCAConfigMap, "apic-ca-configmap", "",
+		"ConfigMap with the CA bundle of the APIC as namespace/name, a bare name is in the aci-containers-system namespace. "+
+			"The system roots are used without a CA bundle.")
+	flag.StringVar(&apicCASecret, "apic-ca-secret", "",
+		"Secret with the CA bundle of the APIC as namespace/name, instead of --apic-ca-configmap.")
+	flag.StringVar(&apicCAKey, "apic-ca-key", "ca.crt",
+		"Key of the CA bundle in the ConfigMap or Secret.")
+	flag.StringVar(&apicServerName, "apic-server-name", "",
+		"Name the APIC certificate is verified against instead of the host, e.g. when the APIC hosts are IP addresses.")
+	flag.BoolVar(&apicInsecureSkipVerify, "apic-insecure-skip-verify", false,
+		"Accept any certificate from the APIC. Only for testing, the connection can be intercepted.")
+	flag.StringVar(&apicCertificateName, "apic-certificate-name", "",
+		"Name of the certificate on the APIC user for private key logins. "+
+			"Defaults to apic-certificate-name of aci-containers-config, or <username>.crt.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -207,6 +279,40 @@ func main() {
 		c.NextProtos = []string{"http/1.1"}
 	}
 
+	secretRef, err := parseObjectRef(apicCredentialsSecret)
+	if err != nil {
+		setupLog.Error(err, "invalid APIC credentials Secret")
+		os.Exit(1)
+	}
+
+	// The startup configuration is read before the manager is created, the
+	// Secret with the credentials decides what the cache watches.
+	restConfig := ctrl.GetConfigOrDie()
+	apiReader, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	cniConfig, credentials, secretRef, err := getStartupConfiguration(apiReader, operatorConfigName, secretRef, credentialKeys, apicCertificateName)
+	if err != nil {
+		setupLog.Error(err, "unable to get startup configuration")
+		os.Exit(1)
+	}
+
+	cacheOptions := cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {
+				Namespaces: map[string]cache.Config{controller.AciContainersNamespace: {}},
+			},
+		},
+	}
+	if secretRef.Name != "" {
+		cacheOptions.ByObject[&corev1.Secret{}] = cache.ByObject{
+			Namespaces: map[string]cache.Config{secretRef.Namespace: {}},
+			Field:      fields.OneTermEqualSelector("metadata.name", secretRef.Name),
+		}
+	}
+
 	if !enableHTTP2 {
 		tlsOpts = append(tlsOpts, disableHTTP2)
 	}
@@ -237,16 +343,20 @@ func main() {
 		// this setup is not recommended for production.
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "327369c9.custom.aci",
+		// ConfigMaps are only watched in the ACI CNI namespace to pick up
+		// changes to the default contracts and the CNI config, and Secrets
+		// only in the namespace of the APIC credentials.
+		Cache: cacheOptions,
 		Client: client.Options{
 			Cache: &client.CacheOptions{
-				DisableFor: []client.Object{&corev1.ConfigMap{}, &corev1.Pod{}},
+				DisableFor: []client.Object{&corev1.ConfigMap{}},
 			},
 		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
@@ -266,34 +376,145 @@ func main() {
 		os.Exit(1)
 	}
 
-	config := mgr.GetConfig()
-	client := mgr.GetClient()
-	clientset, _ := kubernetes.NewForConfig(config)
+	// The UID of kube-system identifies the cluster in the owner tag of the EPGs.
+	kubeSystem := &corev1.Namespace{}
+	err = mgr.GetAPIReader().Get(context.TODO(), types.NamespacedName{Name: "kube-system"}, kubeSystem)
+	if err != nil {
+		setupLog.Error(err, "unable to get cluster ID")
+		os.Exit(1)
+	}
+	cniConfig.ClusterID = string(kubeSystem.GetUID())
 
-	cniConfig, err := getStartupConfiguration(client, clientset, config)
+	if cniConfig.EpgNameTemplate == "" {
+		cniConfig.EpgNameTemplate = epgNameTemplate
+	}
+	cniConfig.AllowedTenants = splitList(allowedTenants)
+	cniConfig.AllowedApplicationProfiles = splitList(allowedApplicationProfiles)
+	cniConfig.AllowedBridgeDomains = splitList(allowedBridgeDomains)
+	cniConfig.AllowedVmmDomains = splitList(allowedVmmDomains)
+	switch epgv1alpha1.DeletionPolicy(defaultDeletionPolicy) {
+	case epgv1alpha1.DeletionPolicyDelete, epgv1alpha1.DeletionPolicyRetain, epgv1alpha1.DeletionPolicyOrphanContractsOnly:
+		cniConfig.DeletionPolicy = epgv1alpha1.DeletionPolicy(defaultDeletionPolicy)
+	default:
+		setupLog.Error(fmt.Errorf("unknown deletion policy %q", defaultDeletionPolicy), "invalid default deletion policy")
+		os.Exit(1)
+	}
+	if clusterName != "" {
+		cniConfig.ClusterName = clusterName
+	}
+	err = controller.ValidateCniConfig(cniConfig)
 	if err != nil {
-		setupLog.Error(err, "unable to get startup configuration")
+		setupLog.Error(err, "invalid operator configuration")
 		os.Exit(1)
 	}
 
-	apicClient, err := aci.NewClient(cniConfig.ApicIp,
-		cniConfig.ApicUsername,
-		cniConfig.ApicPassword,
-		cniConfig.ApicPrivateKey)
+	tlsOptions := aci.TLSOptions{ServerName: apicServerName, InsecureSkipVerify: apicInsecureSkipVerify}
+	caConfigMapRef, err := parseObjectRef(apicCAConfigMap)
+	if err != nil {
+		setupLog.Error(err, "invalid APIC CA ConfigMap")
+		os.Exit(1)
+	}
+	caSecretRef, err := parseObjectRef(apicCASecret)
 	if err != nil {
-		setupLog.Error(err, "unable to setup apic client")
+		setupLog.Error(err, "invalid APIC CA Secret")
 		os.Exit(1)
 	}
+	tlsOptions.CABundle, err = getApicCABundle(mgr.GetAPIReader(), caConfigMapRef, caSecretRef, apicCAKey)
+	if err != nil {
+		setupLog.Error(err, "unable to read the APIC CA bundle")
+		os.Exit(1)
+	}
+	if apicInsecureSkipVerify {
+		setupLog.Info("WARNING: TLS verification of the APIC is disabled by --apic-insecure-skip-verify, " +
+			"any certificate is accepted and the APIC credentials can be intercepted")
+	}
 
-	if err = (&controller.EpgconfReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		CniConfig:  cniConfig,
+	apicClient, err := aci.NewClient(cniConfig.ApicHosts, credentials, tlsOptions)
+	if apicClient == nil {
+		setupLog.Error(err, "unable to create the APIC client")
+		os.Exit(1)
+	}
+	if err != nil {
+		setupLog.Error(err, "unable to log in on the APIC, starting without it until it is reachable",
+			"credentials", credentials.Type(), "hosts", cniConfig.ApicHosts)
+	} else {
+		setupLog.Info("connected to APIC", "host", apicClient.ActiveHost(), "hosts", cniConfig.ApicHosts)
+	}
+	connectivity := &controller.ApicConnectivity{
 		ApicClient: apicClient,
-	}).SetupWithManager(mgr); err != nil {
+		Interval:   apicCheckInterval,
+	}
+	connectivity.Observe(context.TODO(), err)
+	if err = mgr.Add(connectivity); err != nil {
+		setupLog.Error(err, "unable to set up the APIC connectivity check")
+		os.Exit(1)
+	}
+
+	epgconfReconciler := &controller.EpgconfReconciler{
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		CniConfig:          cniConfig,
+		ApicClient:         apicClient,
+		OperatorConfigName: operatorConfigName,
+		EpgNameTemplate:    epgNameTemplate,
+		ResyncInterval:     apicResyncInterval,
+		Connectivity:       connectivity,
+		APIReader:          mgr.GetAPIReader(),
+		Recorder:           mgr.GetEventRecorderFor("epgconf-controller"),
+	}
+	if err = epgconfReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Conf")
 		os.Exit(1)
 	}
+	if err = (&controller.EpgPolicyReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("epgpolicy-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EpgPolicy")
+		os.Exit(1)
+	}
+	if err = (&controller.EpgOperatorConfigReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Name:            operatorConfigName,
+		EpgNameTemplate: epgNameTemplate,
+		SetConfig:       epgconfReconciler.SetCniConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EpgOperatorConfig")
+		os.Exit(1)
+	}
+	if err = (&controller.AciContractReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		ApicClient:   apicClient,
+		Recorder:     mgr.GetEventRecorderFor("acicontract-controller"),
+		Config:       epgconfReconciler.Config,
+		Connectivity: connectivity,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AciContract")
+		os.Exit(1)
+	}
+	if secretRef.Name != "" {
+		if err = (&controller.CredentialsReconciler{
+			Client:      mgr.GetClient(),
+			ApicClient:  apicClient,
+			Secret:      secretRef,
+			Keys:        credentialKeys,
+			Defaults:    apicCredentialDefaults(cniConfig),
+			Credentials: credentials,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ApicCredentials")
+			os.Exit(1)
+		}
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhookv1alpha1.SetupEpgconfWebhookWithManager(mgr, epgconfReconciler.Config); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Epgconf")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,398 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+	"github.com/go-logr/logr"
+	"github.com/samber/lo"
+)
+
+// Reasons used on the AciContract conditions.
+const (
+	reasonInvalidContract  = "InvalidContract"
+	reasonTenantNotAllowed = "TenantNotAllowed"
+	reasonContractApplied  = "ContractApplied"
+	reasonContractNotOwned = "ContractNotOwned"
+)
+
+const aciContractFinalizer = "epg.custom.config/contract-finalizer"
+
+// AciContractReconciler reconciles an AciContract object
+type AciContractReconciler struct {
+	client.Client
+	Scheme     *runtime.Scheme
+	ApicClient aci.ApicInterface
+	Recorder   record.EventRecorder
+	// Config returns the current operator config, for the default tenant
+	// and the allowed tenants.
+	Config func() CniConfig
+	// Connectivity gates the reconciles on the connection to the APIC, they
+	// are not gated when it is nil.
+	Connectivity *ApicConnectivity
+}
+
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=acicontracts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=acicontracts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=acicontracts/finalizers,verbs=update
+
+// Reconcile builds the contract, its subjects and its filters on the APIC
+// from the AciContract, and deletes them with the AciContract.
+func (r *AciContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	contract := &epgv1alpha1.AciContract{}
+	err := r.Get(ctx, req.NamespacedName, contract)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AciContract resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if apicErr := r.Connectivity.Err(); apicErr != nil {
+		l.Info("APIC is unreachable, requeueing", "after", transientRequeueDelay.String())
+		return ctrl.Result{RequeueAfter: transientRequeueDelay}, nil
+	}
+
+	if contract.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(contract, aciContractFinalizer) {
+			err = r.finalizeContract(l, contract)
+			if err != nil {
+				return resultForError(l, err)
+			}
+			controllerutil.RemoveFinalizer(contract, aciContractFinalizer)
+			err = r.Update(ctx, contract)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(contract, aciContractFinalizer) {
+		controllerutil.AddFinalizer(contract, aciContractFinalizer)
+		err = r.Update(ctx, contract)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	contract.Status.ObservedGeneration = contract.GetGeneration()
+	reconcileErr := r.applyContract(l, contract)
+	if reconcileErr != nil {
+		err = r.Status().Update(ctx, contract)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", err)
+		}
+		return resultForError(l, reconcileErr)
+	}
+
+	r.setContractReady(contract, metav1.ConditionTrue, reasonContractApplied, fmt.Sprintf("Contract %s is configured on the APIC", contract.Status.ContractDn))
+	err = r.Status().Update(ctx, contract)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// applyContract creates the filters and then the contract referring to them,
+// and deletes the filters no longer in the spec.
+func (r *AciContractReconciler) applyContract(l logr.Logger, contract *epgv1alpha1.AciContract) error {
+	config := r.Config()
+	tenant := lo.Ternary(contract.Spec.Tenant != "", contract.Spec.Tenant, config.Tenant)
+	if !Allowed(config.AllowedTenants, tenant) {
+		err := fmt.Errorf("the operator is not allowed to configure tenant %s", tenant)
+		r.setContractReady(contract, metav1.ConditionFalse, reasonTenantNotAllowed, err.Error())
+		return reconcile.TerminalError(err)
+	}
+	if contract.Status.ContractDn != "" && contract.Status.ContractDn != aci.ContractDn(contractName(contract), tenant) {
+		err := fmt.Errorf("the contract name and tenant can not be changed, the contract is %s", contract.Status.ContractDn)
+		r.setContractReady(contract, metav1.ConditionFalse, reasonInvalidContract, err.Error())
+		return reconcile.TerminalError(err)
+	}
+
+	desired, filters, err := desiredContract(contract)
+	if err != nil {
+		r.setContractReady(contract, metav1.ConditionFalse, reasonInvalidContract, err.Error())
+		return reconcile.TerminalError(err)
+	}
+
+	owner := contractOwner(config, contract)
+	dns := []string{aci.ContractDn(desired.Name, tenant)}
+	for _, filter := range filters {
+		dns = append(dns, aci.FilterDn(filter.Name, tenant))
+	}
+	untagged, conflict, err := r.checkOwnership(contract, tenant, owner, dns)
+	if err != nil {
+		r.setContractReady(contract, metav1.ConditionFalse, reasonApicError, err.Error())
+		return err
+	}
+	if conflict != "" {
+		err = fmt.Errorf("%s", conflict)
+		r.setContractReady(contract, metav1.ConditionFalse, reasonContractNotOwned, err.Error())
+		return reconcile.TerminalError(err)
+	}
+
+	// The status names the contract and the filters before they are applied,
+	// so that they are claimed again if their owner tag could not be set.
+	filterNames := lo.Map(filters, func(filter aci.Filter, _ int) string { return filter.Name })
+	previousFilters := contract.Status.Filters
+	contract.Status.ContractDn = aci.ContractDn(desired.Name, tenant)
+	contract.Status.Filters = lo.Union(previousFilters, filterNames)
+
+	for _, filter := range filters {
+		err = r.ApicClient.ApplyFilter(tenant, filter)
+		if err != nil {
+			r.setContractReady(contract, metav1.ConditionFalse, reasonApicError, err.Error())
+			return err
+		}
+	}
+	err = r.ApicClient.ApplyContract(tenant, desired)
+	if err != nil {
+		r.setContractReady(contract, metav1.ConditionFalse, reasonApicError, err.Error())
+		return err
+	}
+	for _, dn := range untagged {
+		err = r.ApicClient.SetTag(dn, aci.OwnerTagKey, owner)
+		if err != nil {
+			r.setContractReady(contract, metav1.ConditionFalse, reasonApicError, err.Error())
+			return err
+		}
+	}
+
+	stale, _ := lo.Difference(previousFilters, filterNames)
+	for _, filter := range stale {
+		owned, err := r.ownsObject(l, contract, owner, aci.FilterDn(filter, tenant))
+		if err != nil {
+			r.setContractReady(contract, metav1.ConditionFalse, reasonApicError, err.Error())
+			return err
+		}
+		if !owned {
+			continue
+		}
+		l.Info(fmt.Sprintf("Deleting filter %s, it is no longer used by contract %s", filter, desired.Name))
+		err = r.ApicClient.DeleteFilter(filter, tenant)
+		if err != nil {
+			r.setContractReady(contract, metav1.ConditionFalse, reasonApicError, err.Error())
+			return err
+		}
+	}
+	contract.Status.Filters = filterNames
+	r.Recorder.Eventf(contract, corev1.EventTypeNormal, "ContractApplied", "Applied contract %s", contract.Status.ContractDn)
+	return nil
+}
+
+// contractOwner returns the value of the owner tag on the contract and the
+// filters of the AciContract. AciContracts in other namespaces or clusters
+// may resolve to the same contract on the APIC.
+func contractOwner(config CniConfig, contract *epgv1alpha1.AciContract) string {
+	return fmt.Sprintf("%s/%s/%s", config.ClusterID, contract.GetNamespace(), contract.GetName())
+}
+
+// checkOwnership returns the objects the AciContract may apply but that do
+// not carry its owner tag yet, and describes the first one it may not apply.
+// Objects created by hand, by another AciContract or by another cluster are
+// not taken over, they would be overwritten and deleted with the AciContract.
+// An object created by the operator without owner tag is only claimed when
+// the status of the AciContract names it.
+func (r *AciContractReconciler) checkOwnership(contract *epgv1alpha1.AciContract, tenant, owner string, dns []string) ([]string, string, error) {
+	claimed := lo.Map(contract.Status.Filters, func(filter string, _ int) string { return aci.FilterDn(filter, tenant) })
+	claimed = append(claimed, contract.Status.ContractDn)
+
+	untagged := []string{}
+	for _, dn := range dns {
+		ownership, err := r.ApicClient.ReadOwnership(dn)
+		if err != nil {
+			return nil, "", err
+		}
+		switch {
+		case ownership.Owner == owner:
+		case !ownership.Exists:
+			untagged = append(untagged, dn)
+		case !ownership.Managed:
+			return nil, fmt.Sprintf("%s exists on the APIC and was not created by the operator", dn), nil
+		case ownership.Owner != "":
+			return nil, fmt.Sprintf("%s is owned by %s", dn, ownership.Owner), nil
+		case !lo.Contains(claimed, dn):
+			return nil, fmt.Sprintf("%s was created by the operator for another owner", dn), nil
+		default:
+			untagged = append(untagged, dn)
+		}
+	}
+	return untagged, "", nil
+}
+
+// ownsObject reports if the object at the dn may be deleted with the
+// AciContract, an object another owner took over is kept.
+func (r *AciContractReconciler) ownsObject(l logr.Logger, contract *epgv1alpha1.AciContract, owner, dn string) (bool, error) {
+	ownership, err := r.ApicClient.ReadOwnership(dn)
+	if err != nil {
+		return false, err
+	}
+	if !ownership.Exists {
+		return false, nil
+	}
+	if !ownership.Managed || (ownership.Owner != "" && ownership.Owner != owner) {
+		l.Info(fmt.Sprintf("Keeping %s, it is not owned by the AciContract", dn), "owner", ownership.Owner)
+		r.Recorder.Eventf(contract, corev1.EventTypeWarning, "ContractRetained", "Kept %s, it is owned by %s", dn,
+			lo.Ternary(ownership.Owner != "", ownership.Owner, "someone else"))
+		return false, nil
+	}
+	return true, nil
+}
+
+// finalizeContract deletes the contract and its filters from the APIC.
+func (r *AciContractReconciler) finalizeContract(l logr.Logger, contract *epgv1alpha1.AciContract) error {
+	if contract.Status.ContractDn == "" {
+		return nil
+	}
+	name, tenant, err := aci.ParseContractDn(contract.Status.ContractDn)
+	if err != nil {
+		return err
+	}
+	owner := contractOwner(r.Config(), contract)
+
+	owned, err := r.ownsObject(l, contract, owner, contract.Status.ContractDn)
+	if err != nil {
+		return fmt.Errorf("error occurred while checking the owner of contract: %w", err)
+	}
+	if owned {
+		l.Info(fmt.Sprintf("Deleting contract %s", contract.Status.ContractDn))
+		err = r.ApicClient.DeleteContract(name, tenant)
+		if err != nil {
+			return fmt.Errorf("error occurred while deleting contract: %w", err)
+		}
+		r.Recorder.Eventf(contract, corev1.EventTypeNormal, "ContractDeleted", "Deleted contract %s", contract.Status.ContractDn)
+	}
+	for _, filter := range contract.Status.Filters {
+		owned, err = r.ownsObject(l, contract, owner, aci.FilterDn(filter, tenant))
+		if err != nil {
+			return fmt.Errorf("error occurred while checking the owner of filter: %w", err)
+		}
+		if !owned {
+			continue
+		}
+		err = r.ApicClient.DeleteFilter(filter, tenant)
+		if err != nil {
+			return fmt.Errorf("error occurred while deleting filter: %w", err)
+		}
+	}
+	return nil
+}
+
+// contractName returns the name of the contract on the APIC.
+func contractName(contract *epgv1alpha1.AciContract) string {
+	return lo.Ternary(contract.Spec.ContractName != "", contract.Spec.ContractName, contract.GetName())
+}
+
+// desiredContract returns the contract and the filters it uses as they are
+// built on the APIC. The filters are named after the contract, filters with
+// the same name in several subjects must be identical.
+func desiredContract(contract *epgv1alpha1.AciContract) (aci.Contract, []aci.Filter, error) {
+	name := contractName(contract)
+	err := aci.ValidateName(name)
+	if err != nil {
+		return aci.Contract{}, nil, fmt.Errorf("invalid contract name: %w", err)
+	}
+
+	desired := aci.Contract{Name: name, Scope: string(contract.Spec.Scope)}
+	if desired.Scope == "" {
+		desired.Scope = string(epgv1alpha1.ContractScopeVrf)
+	}
+	filters := []aci.Filter{}
+	for _, subject := range contract.Spec.Subjects {
+		desiredSubject := aci.ContractSubject{
+			Name:               subject.Name,
+			ReverseFilterPorts: subject.ReverseFilterPorts == nil || *subject.ReverseFilterPorts,
+		}
+		for _, filter := range subject.Filters {
+			filterName := fmt.Sprintf("%s_%s", name, filter.Name)
+			err = aci.ValidateName(filterName)
+			if err != nil {
+				return aci.Contract{}, nil, fmt.Errorf("invalid filter name: %w", err)
+			}
+			desiredFilter := aci.Filter{Name: filterName}
+			for _, entry := range filter.Entries {
+				desiredFilter.Entries = append(desiredFilter.Entries, filterEntry(entry))
+			}
+
+			if existing, found := lo.Find(filters, func(f aci.Filter) bool { return f.Name == filterName }); found {
+				if len(existing.Entries) != len(desiredFilter.Entries) || !lo.Every(existing.Entries, desiredFilter.Entries) {
+					return aci.Contract{}, nil, fmt.Errorf("filter %s is defined differently in several subjects", filter.Name)
+				}
+			} else {
+				filters = append(filters, desiredFilter)
+			}
+			desiredSubject.Filters = append(desiredSubject.Filters, filterName)
+		}
+		desired.Subjects = append(desired.Subjects, desiredSubject)
+	}
+	return desired, filters, nil
+}
+
+// filterEntry converts a filter entry of the spec, a single port is a range
+// starting and ending at the port.
+func filterEntry(entry epgv1alpha1.FilterEntry) aci.FilterEntry {
+	desired := aci.FilterEntry{Name: entry.Name, Protocol: string(entry.Protocol)}
+	if desired.Protocol == "" {
+		desired.Protocol = "tcp"
+	}
+	if entry.Port != 0 {
+		desired.FromPort = strconv.Itoa(int(entry.Port))
+		desired.ToPort = desired.FromPort
+		if entry.EndPort != 0 {
+			desired.ToPort = strconv.Itoa(int(entry.EndPort))
+		}
+	}
+	return desired
+}
+
+func (r *AciContractReconciler) setContractReady(contract *epgv1alpha1.AciContract, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&contract.Status.Conditions, metav1.Condition{
+		Type:               epgv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: contract.GetGeneration(),
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AciContractReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&epgv1alpha1.AciContract{}).
+		Complete(r)
+}
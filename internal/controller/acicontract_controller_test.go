@@ -0,0 +1,265 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+)
+
+var _ = Describe("AciContract Controller", func() {
+	ctx := context.Background()
+
+	It("Should create and delete the contract and its filters", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-contract"}}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+
+		contract := &v1alpha1.AciContract{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: namespace.Name},
+			Spec: v1alpha1.AciContractSpec{
+				Subjects: []v1alpha1.ContractSubject{{
+					Name: "postgres",
+					Filters: []v1alpha1.ContractFilter{{
+						Name:    "postgres",
+						Entries: []v1alpha1.FilterEntry{{Name: "tcp-5432", Port: 5432}},
+					}},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, contract)).Should(Succeed())
+
+		reconciler := &AciContractReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			Recorder:   record.NewFakeRecorder(1024),
+			Config:     func() CniConfig { return cniConf },
+		}
+		key := types.NamespacedName{Name: contract.Name, Namespace: namespace.Name}
+		mock := apicClient.(*aci.ApicClientMocks)
+
+		By("Creating the contract on the APIC", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, contract)).Should(Succeed())
+			Expect(contract.Status.ContractDn).Should(Equal(aci.ContractDn("postgres", cniConf.Tenant)))
+			Expect(contract.Status.Filters).Should(Equal([]string{"postgres_postgres"}))
+			Expect(meta.IsStatusConditionTrue(contract.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+
+			created, exists := mock.GetContract("postgres", cniConf.Tenant)
+			Expect(exists).Should(BeTrue())
+			Expect(created.Scope).Should(Equal(string(v1alpha1.ContractScopeVrf)))
+			Expect(created.Subjects).Should(HaveLen(1))
+			Expect(created.Subjects[0].ReverseFilterPorts).Should(BeTrue())
+			Expect(created.Subjects[0].Filters).Should(Equal([]string{"postgres_postgres"}))
+
+			filter, exists := mock.GetFilter("postgres_postgres", cniConf.Tenant)
+			Expect(exists).Should(BeTrue())
+			Expect(filter.Entries).Should(Equal([]aci.FilterEntry{{Name: "tcp-5432", Protocol: "tcp", FromPort: "5432", ToPort: "5432"}}))
+		})
+
+		By("Deleting the contract from the APIC", func() {
+			Expect(k8sClient.Delete(ctx, contract)).Should(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, key, &v1alpha1.AciContract{})
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+			_, exists := mock.GetContract("postgres", cniConf.Tenant)
+			Expect(exists).Should(BeFalse())
+			_, exists = mock.GetFilter("postgres_postgres", cniConf.Tenant)
+			Expect(exists).Should(BeFalse())
+		})
+	})
+
+	It("Should not take over a contract created by hand", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-contract-unmanaged"}}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+		mock := apicClient.(*aci.ApicClientMocks)
+		mock.CreateUnmanagedContract("handmade", cniConf.Tenant)
+
+		contract := &v1alpha1.AciContract{
+			ObjectMeta: metav1.ObjectMeta{Name: "handmade", Namespace: namespace.Name},
+			Spec: v1alpha1.AciContractSpec{
+				Subjects: []v1alpha1.ContractSubject{{
+					Name: "web",
+					Filters: []v1alpha1.ContractFilter{{
+						Name:    "web",
+						Entries: []v1alpha1.FilterEntry{{Name: "tcp-443", Port: 443}},
+					}},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, contract)).Should(Succeed())
+
+		reconciler := &AciContractReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			Recorder:   record.NewFakeRecorder(1024),
+			Config:     func() CniConfig { return cniConf },
+		}
+		key := types.NamespacedName{Name: contract.Name, Namespace: namespace.Name}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).Should(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, contract)).Should(Succeed())
+		Expect(contract.Status.ContractDn).Should(BeEmpty())
+		ready := meta.FindStatusCondition(contract.Status.Conditions, v1alpha1.ConditionReady)
+		Expect(ready).ShouldNot(BeNil())
+		Expect(ready.Reason).Should(Equal(reasonContractNotOwned))
+		_, exists := mock.GetFilter("handmade_web", cniConf.Tenant)
+		Expect(exists).Should(BeFalse())
+
+		Expect(k8sClient.Delete(ctx, contract)).Should(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		_, exists = mock.GetContract("handmade", cniConf.Tenant)
+		Expect(exists).Should(BeTrue())
+	})
+
+	It("Should not share a contract with AciContracts of other namespaces or clusters", func() {
+		mock := apicClient.(*aci.ApicClientMocks)
+		reconciler := &AciContractReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			Recorder:   record.NewFakeRecorder(1024),
+			Config:     func() CniConfig { return cniConf },
+		}
+
+		keys := []types.NamespacedName{}
+		for _, team := range []string{"a", "b"} {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-contract-owner-" + team}}
+			Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+			contract := &v1alpha1.AciContract{
+				ObjectMeta: metav1.ObjectMeta{Name: "same-name", Namespace: namespace.Name},
+				Spec: v1alpha1.AciContractSpec{
+					Subjects: []v1alpha1.ContractSubject{{
+						Name: "web",
+						Filters: []v1alpha1.ContractFilter{{
+							Name:    "web",
+							Entries: []v1alpha1.FilterEntry{{Name: "tcp-" + team, Port: 443}},
+						}},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, contract)).Should(Succeed())
+			keys = append(keys, types.NamespacedName{Name: contract.Name, Namespace: namespace.Name})
+		}
+		contractDn := aci.ContractDn("same-name", cniConf.Tenant)
+		owner := "test-cluster/ns-contract-owner-a/same-name"
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[0]})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mock.GetTag(contractDn, aci.OwnerTagKey)).Should(Equal(owner))
+		Expect(mock.GetTag(aci.FilterDn("same-name_web", cniConf.Tenant), aci.OwnerTagKey)).Should(Equal(owner))
+
+		By("Refusing the contract to another namespace", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[1]})
+			Expect(err).Should(HaveOccurred())
+
+			contract := &v1alpha1.AciContract{}
+			Expect(k8sClient.Get(ctx, keys[1], contract)).Should(Succeed())
+			ready := meta.FindStatusCondition(contract.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready).ShouldNot(BeNil())
+			Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).Should(Equal(reasonContractNotOwned))
+			Expect(ready.Message).Should(ContainSubstring("owned by " + owner))
+			filter, _ := mock.GetFilter("same-name_web", cniConf.Tenant)
+			Expect(filter.Entries[0].Name).Should(Equal("tcp-a"))
+
+			Expect(k8sClient.Delete(ctx, contract)).Should(Succeed())
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[1]})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, exists := mock.GetContract("same-name", cniConf.Tenant)
+			Expect(exists).Should(BeTrue())
+		})
+
+		By("Refusing the contract to another cluster", func() {
+			otherCluster := cniConf
+			otherCluster.ClusterID = "other-cluster"
+			other := &AciContractReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				ApicClient: apicClient,
+				Recorder:   record.NewFakeRecorder(1024),
+				Config:     func() CniConfig { return otherCluster },
+			}
+			_, err := other.Reconcile(ctx, ctrl.Request{NamespacedName: keys[0]})
+			Expect(err).Should(HaveOccurred())
+			Expect(mock.GetTag(contractDn, aci.OwnerTagKey)).Should(Equal(owner))
+		})
+
+		By("Deleting the contract with its owner", func() {
+			contract := &v1alpha1.AciContract{}
+			Expect(k8sClient.Get(ctx, keys[0], contract)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, contract)).Should(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[0]})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, exists := mock.GetContract("same-name", cniConf.Tenant)
+			Expect(exists).Should(BeFalse())
+		})
+	})
+
+	It("Should reject port ranges ending before they start", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-contract-ports"}}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+
+		for name, entry := range map[string]v1alpha1.FilterEntry{
+			"reversed":     {Name: "tcp", Port: 443, EndPort: 80},
+			"without-port": {Name: "tcp", EndPort: 443},
+		} {
+			contract := &v1alpha1.AciContract{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.Name},
+				Spec: v1alpha1.AciContractSpec{
+					Subjects: []v1alpha1.ContractSubject{{
+						Name:    "web",
+						Filters: []v1alpha1.ContractFilter{{Name: "web", Entries: []v1alpha1.FilterEntry{entry}}},
+					}},
+				},
+			}
+			err := k8sClient.Create(ctx, contract)
+			Expect(errors.IsInvalid(err)).Should(BeTrue(), name)
+			Expect(err.Error()).Should(ContainSubstring("endPort requires port"))
+		}
+
+		contract := &v1alpha1.AciContract{
+			ObjectMeta: metav1.ObjectMeta{Name: "range", Namespace: namespace.Name},
+			Spec: v1alpha1.AciContractSpec{
+				Subjects: []v1alpha1.ContractSubject{{
+					Name:    "web",
+					Filters: []v1alpha1.ContractFilter{{Name: "web", Entries: []v1alpha1.FilterEntry{{Name: "tcp", Port: 8080, EndPort: 8090}}}},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, contract)).Should(Succeed())
+	})
+})
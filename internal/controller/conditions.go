@@ -0,0 +1,70 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+// Reasons used on the Epgconf conditions.
+const (
+	reasonSucceeded       = "Succeeded"
+	reasonReconciled      = "Reconciled"
+	reasonReconcileFailed = "ReconcileFailed"
+	reasonApicError       = "ApicError"
+	reasonKubernetesError = "KubernetesError"
+	reasonInvalidEpgName  = "InvalidEpgName"
+	reasonNotAllowed      = "NotAllowed"
+	reasonEpgNotOwned     = "EpgNotOwned"
+	reasonEpgNotShared    = "EpgNotShared"
+	reasonDriftDetected   = "DriftDetected"
+	reasonDriftRepaired   = "DriftRepaired"
+	reasonNoDrift         = "NoDrift"
+	reasonContractMissing = "ContractMissing"
+	reasonContractsFound  = "ContractsFound"
+	reasonApicUnavailable = "ApicUnavailable"
+	reasonApicReachable   = "ApicReachable"
+)
+
+func setCondition(conf *epgv1alpha1.Epgconf, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&conf.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: conf.GetGeneration(),
+	})
+}
+
+// setConditionTrue marks the condition as true and reports if it was not true before.
+func setConditionTrue(conf *epgv1alpha1.Epgconf, conditionType, reason, message string) bool {
+	wasTrue := meta.IsStatusConditionTrue(conf.Status.Conditions, conditionType)
+	setCondition(conf, conditionType, metav1.ConditionTrue, reason, message)
+	return !wasTrue
+}
+
+// setConditionFalse marks the condition as failed with the error as message.
+// Errors returned by the APIC are also kept in the LastApicError status field.
+func setConditionFalse(conf *epgv1alpha1.Epgconf, conditionType, reason string, err error) {
+	if reason == reasonApicError {
+		conf.Status.LastApicError = err.Error()
+	}
+	setCondition(conf, conditionType, metav1.ConditionFalse, reason, err.Error())
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+const (
+	// AciContainersNamespace is the namespace of the ACI CNI components.
+	AciContainersNamespace = "aci-containers-system"
+	// AciContainersConfigName is the ConfigMap holding the ACI CNI controller config.
+	AciContainersConfigName = "aci-containers-config"
+	// DefaultContractsConfigName is the ConfigMap holding the default contracts.
+	DefaultContractsConfigName = "default-epg-contracts"
+	// DefaultOperatorConfigName is the EpgOperatorConfig used unless another
+	// one is given with a flag.
+	DefaultOperatorConfigName = "default"
+)
+
+// LoadCniConfig builds the CniConfig from the EpgOperatorConfig with the name
+// and the ConfigMaps of the ACI CNI, which are used as defaults. Any of them
+// may be missing, the EpgOperatorConfig is nil when it does not exist and the
+// bool tells if aci-containers-config was found.
+func LoadCniConfig(ctx context.Context, c client.Reader, name string) (CniConfig, *epgv1alpha1.EpgOperatorConfig, bool, error) {
+	contractsConfig := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: AciContainersNamespace, Name: DefaultContractsConfigName}, contractsConfig)
+	if err != nil && !errors.IsNotFound(err) {
+		return CniConfig{}, nil, false, err
+	}
+
+	cniConfig := CniConfig{
+		ProvidedContracts: stringList(gjson.Parse(contractsConfig.Data["provided"])),
+		ConsumedContracts: stringList(gjson.Parse(contractsConfig.Data["consumed"])),
+	}
+	aciContainersConfig := &corev1.ConfigMap{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: AciContainersNamespace, Name: AciContainersConfigName}, aciContainersConfig)
+	found := err == nil
+	switch {
+	case found:
+		cniConfig, err = CniConfigFromConfigMaps(aciContainersConfig, contractsConfig)
+		if err != nil {
+			return CniConfig{}, nil, true, err
+		}
+	case !errors.IsNotFound(err):
+		return CniConfig{}, nil, false, err
+	}
+
+	operatorConfig := &epgv1alpha1.EpgOperatorConfig{}
+	err = c.Get(ctx, types.NamespacedName{Name: name}, operatorConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return cniConfig, nil, found, nil
+		}
+		return CniConfig{}, nil, found, err
+	}
+	return ApplyOperatorConfig(cniConfig, operatorConfig.Spec), operatorConfig, found, nil
+}
+
+// ApplyOperatorConfig overrides the CniConfig with the fields set in the
+// EpgOperatorConfig.
+func ApplyOperatorConfig(cniConfig CniConfig, spec epgv1alpha1.EpgOperatorConfigSpec) CniConfig {
+	override := func(value *string, specValue string) {
+		if specValue != "" {
+			*value = specValue
+		}
+	}
+	if len(spec.ApicHosts) > 0 {
+		cniConfig.ApicHosts = spec.ApicHosts
+	}
+	override(&cniConfig.ApicUsername, spec.ApicUsername)
+	override(&cniConfig.Tenant, spec.Tenant)
+	override(&cniConfig.ApplicationProfile, spec.ApplicationProfile)
+	override(&cniConfig.BridgeDomain, spec.BridgeDomain)
+	override(&cniConfig.Vrf, spec.Vrf)
+	override(&cniConfig.VmmDomain, spec.VmmDomain)
+	override(&cniConfig.VmmDomainType, spec.VmmDomainType)
+	override(&cniConfig.EpgNameTemplate, spec.EpgNameTemplate)
+	if spec.ProvidedContracts != nil {
+		cniConfig.ProvidedContracts = spec.ProvidedContracts
+	}
+	if spec.ConsumedContracts != nil {
+		cniConfig.ConsumedContracts = spec.ConsumedContracts
+	}
+	return cniConfig
+}
+
+// ValidateCniConfig checks that the settings the operator cannot work
+// without are present and that the naming template renders.
+func ValidateCniConfig(cniConfig CniConfig) error {
+	missing := []string{}
+	for _, setting := range []struct {
+		name  string
+		unset bool
+	}{
+		{"apicHosts", len(cniConfig.ApicHosts) == 0},
+		{"tenant", cniConfig.Tenant == ""},
+		{"applicationProfile", cniConfig.ApplicationProfile == ""},
+		{"bridgeDomain", cniConfig.BridgeDomain == ""},
+		{"vmmDomain", cniConfig.VmmDomain == ""},
+		{"vmmDomainType", cniConfig.VmmDomainType == ""},
+	} {
+		if setting.unset {
+			missing = append(missing, setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s not set in the EpgOperatorConfig or aci-containers-config", strings.Join(missing, ", "))
+	}
+	if !Allowed(cniConfig.AllowedVmmDomains, cniConfig.VmmDomain) {
+		return fmt.Errorf("VMM domain %s is not in the allowed VMM domains", cniConfig.VmmDomain)
+	}
+
+	if cniConfig.EpgNameTemplate != "" {
+		_, err := RenderEpgName(cniConfig.EpgNameTemplate, EpgNameData{Namespace: "default", Name: "epgconf", Cluster: cniConfig.ClusterName})
+		if err != nil {
+			return fmt.Errorf("invalid EPG name template: %w", err)
+		}
+	}
+	return nil
+}
+
+// CniConfigFromConfigMaps builds the CniConfig from the aci-containers-config
+// and default-epg-contracts ConfigMaps. APIC credentials are not part of the
+// ConfigMaps and are left empty.
+func CniConfigFromConfigMaps(aciContainersConfig, contractsConfig *corev1.ConfigMap) (CniConfig, error) {
+	controllerConfig := aciContainersConfig.Data["controller-config"]
+	if controllerConfig == "" {
+		return CniConfig{}, fmt.Errorf("controller-config is missing in ConfigMap %s", aciContainersConfig.Name)
+	}
+
+	podBdDn := strings.Split(gjson.Get(controllerConfig, "aci-podbd-dn").String(), "/")
+	if len(podBdDn) < 3 {
+		return CniConfig{}, fmt.Errorf("could not parse aci-podbd-dn in ConfigMap %s", aciContainersConfig.Name)
+	}
+	if len(gjson.Get(controllerConfig, "apic-hosts").Array()) == 0 {
+		return CniConfig{}, fmt.Errorf("apic-hosts is missing in ConfigMap %s", aciContainersConfig.Name)
+	}
+
+	return CniConfig{
+		ApicHosts:           stringList(gjson.Get(controllerConfig, "apic-hosts")),
+		ApicUsername:        gjson.Get(controllerConfig, "apic-username").String(),
+		ApicCertificateName: gjson.Get(controllerConfig, "apic-certificate-name").String(),
+		Tenant:              gjson.Get(controllerConfig, "aci-policy-tenant").String(),
+		BridgeDomain:        strings.Replace(podBdDn[2], "BD-", "", -1),
+		VmmDomain:           gjson.Get(controllerConfig, "aci-vmm-domain").String(),
+		VmmDomainType:       gjson.Get(controllerConfig, "aci-vmm-type").String(),
+		ApplicationProfile:  gjson.Get(controllerConfig, "app-profile").String(),
+		Vrf:                 gjson.Get(controllerConfig, "aci-vrf").String(),
+		ClusterName:         gjson.Get(controllerConfig, "aci-prefix").String(),
+		ProvidedContracts:   stringList(gjson.Parse(contractsConfig.Data["provided"])),
+		ConsumedContracts:   stringList(gjson.Parse(contractsConfig.Data["consumed"])),
+	}, nil
+}
+
+func stringList(data gjson.Result) []string {
+	items := data.Array()
+	list := make([]string, len(items))
+	for i, item := range items {
+		list[i] = item.String()
+	}
+	return list
+}
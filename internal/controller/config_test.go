@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+var _ = Describe("CniConfig", func() {
+	ctx := context.Background()
+
+	aciContainersConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AciContainersConfigName,
+			Namespace: AciContainersNamespace,
+		},
+		Data: map[string]string{
+			"controller-config": `{
+				"apic-hosts": ["10.0.0.1", "10.0.0.2"],
+				"apic-username": "ocpaci",
+				"apic-certificate-name": "ocpaci-operator.crt",
+				"apic-private-key-path": "/usr/local/etc/aci-cert/user.key",
+				"aci-policy-tenant": "optest",
+				"aci-podbd-dn": "uni/tn-optest/BD-optest-pod-bd",
+				"aci-vmm-domain": "ocpaci",
+				"aci-vmm-type": "OpenShift",
+				"app-profile": "aci-containers-optest",
+				"aci-vrf": "optest-vrf"
+			}`,
+		},
+	}
+	contractsConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultContractsConfigName,
+			Namespace: AciContainersNamespace,
+		},
+		Data: map[string]string{
+			"provided": `["web"]`,
+			"consumed": `["dns", "ntp"]`,
+		},
+	}
+
+	It("Should parse the ConfigMaps", func() {
+		config, err := CniConfigFromConfigMaps(aciContainersConfig, contractsConfig)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config.ApicHosts).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		Expect(config.ApicCertificateName).Should(Equal("ocpaci-operator.crt"))
+		Expect(config.Tenant).Should(Equal("optest"))
+		Expect(config.BridgeDomain).Should(Equal("optest-pod-bd"))
+		Expect(config.ApplicationProfile).Should(Equal("aci-containers-optest"))
+		Expect(config.Vrf).Should(Equal("optest-vrf"))
+		Expect(config.ProvidedContracts).Should(Equal([]string{"web"}))
+		Expect(config.ConsumedContracts).Should(Equal([]string{"dns", "ntp"}))
+	})
+
+	It("Should fail without controller-config", func() {
+		_, err := CniConfigFromConfigMaps(&corev1.ConfigMap{}, contractsConfig)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should override the ConfigMaps with the EpgOperatorConfig", func() {
+		config, err := CniConfigFromConfigMaps(aciContainersConfig, contractsConfig)
+		Expect(err).ShouldNot(HaveOccurred())
+		config = ApplyOperatorConfig(config, v1alpha1.EpgOperatorConfigSpec{
+			Tenant:            "other",
+			ConsumedContracts: []string{},
+		})
+		Expect(config.Tenant).Should(Equal("other"))
+		Expect(config.BridgeDomain).Should(Equal("optest-pod-bd"))
+		Expect(config.ProvidedContracts).Should(Equal([]string{"web"}))
+		Expect(config.ConsumedContracts).Should(BeEmpty())
+	})
+
+	It("Should report the missing settings", func() {
+		err := ValidateCniConfig(CniConfig{ApicHosts: []string{"10.0.0.1"}, Tenant: "optest"})
+		Expect(err).Should(MatchError(ContainSubstring("applicationProfile, bridgeDomain, vmmDomain, vmmDomainType")))
+
+		config, err := CniConfigFromConfigMaps(aciContainersConfig, contractsConfig)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ValidateCniConfig(config)).Should(Succeed())
+		config.AllowedVmmDomains = []string{"other"}
+		Expect(ValidateCniConfig(config)).Should(MatchError(ContainSubstring("VMM domain ocpaci")))
+		config.AllowedVmmDomains = nil
+		config.EpgNameTemplate = "{{ .Unknown }}"
+		Expect(ValidateCniConfig(config)).ShouldNot(Succeed())
+	})
+
+	It("Should reload the default contracts and keep the credentials", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: AciContainersNamespace}}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+		Expect(k8sClient.Create(ctx, aciContainersConfig.DeepCopy())).Should(Succeed())
+		Expect(k8sClient.Create(ctx, contractsConfig.DeepCopy())).Should(Succeed())
+
+		reconciler := &EpgconfReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			CniConfig:  CniConfig{ApicHosts: []string{"10.0.0.1"}, ApicPassword: "secret"},
+			Recorder:   record.NewFakeRecorder(1024),
+		}
+		Expect(reconciler.ReloadCniConfig(ctx)).Should(Succeed())
+		Expect(reconciler.CniConfig.ApicPassword).Should(Equal("secret"))
+		Expect(reconciler.CniConfig.ConsumedContracts).Should(Equal([]string{"dns", "ntp"}))
+	})
+})
@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+const (
+	// DefaultApicCheckInterval is how often the connection to a reachable
+	// APIC is checked.
+	DefaultApicCheckInterval = time.Minute
+	// apicCheckBaseDelay is the delay before checking an unreachable APIC
+	// again, it doubles for every failed check up to the check interval.
+	apicCheckBaseDelay = 5 * time.Second
+)
+
+// ConnectionChecker is the part of the APIC client that checks the
+// connection.
+type ConnectionChecker interface {
+	CheckConnection() error
+}
+
+// ApicConnectivity checks the connection to the APIC in the background so
+// that the manager keeps running while the APIC is unreachable, for instance
+// during a maintenance window. Reconciles that need the APIC are skipped while
+// it is unreachable. The Epgconfs are enqueued when the APIC becomes
+// unreachable, to report it in their status, and again once it is back.
+type ApicConnectivity struct {
+	ApicClient ConnectionChecker
+	// Interval is how often a reachable APIC is checked, an unreachable one
+	// is checked with backoff up to the interval.
+	Interval time.Duration
+
+	lock    sync.RWMutex
+	err     error
+	changes chan event.GenericEvent
+}
+
+// Start checks the connection until the context is done.
+func (a *ApicConnectivity) Start(ctx context.Context) error {
+	interval := a.Interval
+	if interval <= 0 {
+		interval = DefaultApicCheckInterval
+	}
+	delay := apicCheckBaseDelay
+	for {
+		wait := interval
+		if !a.Connected() {
+			wait = min(delay, interval)
+			delay = min(delay*2, interval)
+		} else {
+			delay = apicCheckBaseDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		a.Observe(ctx, a.ApicClient.CheckConnection())
+	}
+}
+
+// Observe records the result of a connection check, such as the login done
+// when the APIC client is created.
+func (a *ApicConnectivity) Observe(ctx context.Context, err error) {
+	l := log.FromContext(ctx).WithName("apic-connectivity")
+
+	a.lock.Lock()
+	wasConnected := a.err == nil
+	a.err = err
+	a.lock.Unlock()
+
+	switch {
+	case err != nil && wasConnected:
+		l.Error(err, "APIC is unreachable, pausing reconciles")
+		apicReachable.Set(0)
+		a.notify()
+	case err == nil && !wasConnected:
+		l.Info("APIC is reachable again, resuming reconciles")
+		apicReachable.Set(1)
+		a.notify()
+	case err == nil:
+		apicReachable.Set(1)
+	default:
+		l.V(1).Info("APIC is still unreachable", "error", err.Error())
+	}
+}
+
+// Connected reports if the last check reached the APIC. A nil
+// ApicConnectivity is always connected.
+func (a *ApicConnectivity) Connected() bool {
+	return a.Err() == nil
+}
+
+// Err returns the error of the last check, nil when the APIC was reached.
+func (a *ApicConnectivity) Err() error {
+	if a == nil {
+		return nil
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.err
+}
+
+// Changes returns the channel receiving an event when the APIC becomes
+// unreachable or reachable again. Events are coalesced, a pending event is
+// not repeated.
+func (a *ApicConnectivity) Changes() <-chan event.GenericEvent {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.changes == nil {
+		a.changes = make(chan event.GenericEvent, 1)
+	}
+	return a.changes
+}
+
+func (a *ApicConnectivity) notify() {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.changes == nil {
+		return
+	}
+	select {
+	case a.changes <- event.GenericEvent{Object: &epgv1alpha1.Epgconf{}}:
+	default:
+	}
+}
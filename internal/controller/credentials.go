@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+)
+
+// CredentialKeys are the keys of the APIC credentials in the Secret.
+type CredentialKeys struct {
+	Username        string
+	Password        string
+	PrivateKey      string
+	Certificate     string
+	CertificateName string
+}
+
+// DefaultCredentialKeys are the keys used unless they are overridden with flags.
+var DefaultCredentialKeys = CredentialKeys{
+	Username:        "username",
+	Password:        "password",
+	PrivateKey:      "private-key",
+	Certificate:     "certificate",
+	CertificateName: "certificate-name",
+}
+
+// CredentialsFromSecret reads the APIC credentials from the Secret. A private
+// key is preferred over a password, and is checked against the certificate
+// when the Secret has one. The username and certificate name fall back to the
+// defaults.
+func CredentialsFromSecret(secret *corev1.Secret, keys CredentialKeys, defaults aci.Credentials) (aci.Credentials, error) {
+	credentials := aci.Credentials{Username: defaults.Username, CertificateName: defaults.CertificateName}
+	if value := string(secret.Data[keys.Username]); value != "" {
+		credentials.Username = value
+	}
+	if value := string(secret.Data[keys.CertificateName]); value != "" {
+		credentials.CertificateName = value
+	}
+	if credentials.Username == "" {
+		return aci.Credentials{}, fmt.Errorf("secret %s has no %s and no APIC username is configured", secret.Name, keys.Username)
+	}
+
+	key := secret.Data[keys.PrivateKey]
+	password := secret.Data[keys.Password]
+	switch {
+	case len(key) > 0:
+		if certificate := secret.Data[keys.Certificate]; len(certificate) > 0 {
+			_, err := tls.X509KeyPair(certificate, key)
+			if err != nil {
+				return aci.Credentials{}, fmt.Errorf("%s and %s in secret %s do not match: %w", keys.Certificate, keys.PrivateKey, secret.Name, err)
+			}
+		}
+		credentials.PrivateKey = string(key)
+	case len(password) > 0:
+		credentials.Password = string(password)
+	default:
+		return aci.Credentials{}, fmt.Errorf("secret %s has neither %s nor %s", secret.Name, keys.PrivateKey, keys.Password)
+	}
+	return credentials, nil
+}
+
+// CredentialsSetter is the part of the APIC client that takes new credentials.
+type CredentialsSetter interface {
+	SetCredentials(credentials aci.Credentials) error
+}
+
+// CredentialsReconciler watches the Secret with the APIC credentials and
+// hands them to the APIC client when they are rotated.
+type CredentialsReconciler struct {
+	client.Client
+	ApicClient CredentialsSetter
+	// Secret is the Secret holding the APIC credentials.
+	Secret types.NamespacedName
+	Keys   CredentialKeys
+	// Defaults are the username and certificate name used when the Secret
+	// has none.
+	Defaults aci.Credentials
+	// Credentials are the credentials the APIC client uses.
+	Credentials aci.Credentials
+}
+
+// The operator may only read Secrets in the namespace of the ACI CNI, the role
+// is in config/credentials. Watches are allowed because the cache selects the
+// Secret by name.
+
+// Reconcile passes changed credentials to the APIC client. A deleted Secret
+// keeps the current credentials.
+func (r *CredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, req.NamespacedName, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("APIC credentials Secret not found, keeping the current credentials")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	credentials, err := CredentialsFromSecret(secret, r.Keys, r.Defaults)
+	if err != nil {
+		l.Error(err, "invalid APIC credentials, keeping the current credentials")
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if credentials == r.Credentials {
+		return ctrl.Result{}, nil
+	}
+
+	l.Info("APIC credentials changed, reconnecting", "username", credentials.Username)
+	err = r.ApicClient.SetCredentials(credentials)
+	if err != nil {
+		return resultForError(l, err)
+	}
+	r.Credentials = credentials
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("apic-credentials").
+		For(&corev1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return o.GetNamespace() == r.Secret.Namespace && o.GetName() == r.Secret.Name
+		}))).
+		Complete(r)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+)
+
+type fakeCredentialsSetter struct {
+	credentials []aci.Credentials
+}
+
+func (f *fakeCredentialsSetter) SetCredentials(credentials aci.Credentials) error {
+	f.credentials = append(f.credentials, credentials)
+	return nil
+}
+
+var _ = Describe("APIC credentials", func() {
+	ctx := context.Background()
+
+	secret := func(data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "apic-credentials"}, Data: map[string][]byte{}}
+		for key, value := range data {
+			s.Data[key] = []byte(value)
+		}
+		return s
+	}
+
+	It("Should read a password and fall back to the configured username", func() {
+		credentials, err := CredentialsFromSecret(secret(map[string]string{"password": "secret"}), DefaultCredentialKeys, aci.Credentials{Username: "ocpaci"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(credentials).Should(Equal(aci.Credentials{Username: "ocpaci", Password: "secret"}))
+	})
+
+	It("Should prefer the private key and use the key names", func() {
+		keys := DefaultCredentialKeys
+		keys.PrivateKey = "user.key"
+		credentials, err := CredentialsFromSecret(secret(map[string]string{"username": "operator", "password": "secret", "user.key": "key"}), keys, aci.Credentials{Username: "ocpaci"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(credentials).Should(Equal(aci.Credentials{Username: "operator", PrivateKey: "key"}))
+	})
+
+	It("Should read the certificate name", func() {
+		credentials, err := CredentialsFromSecret(secret(map[string]string{"private-key": "key", "certificate-name": "operator"}), DefaultCredentialKeys, aci.Credentials{Username: "ocpaci", CertificateName: "ocpaci.crt"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(credentials.CertificateName).Should(Equal("operator"))
+		Expect(credentials.Type()).Should(Equal("private key of certificate uni/userext/user-ocpaci/usercert-operator"))
+	})
+
+	It("Should fail without a password or key", func() {
+		_, err := CredentialsFromSecret(secret(map[string]string{"username": "operator"}), DefaultCredentialKeys, aci.Credentials{})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should fail on a certificate not matching the key", func() {
+		_, err := CredentialsFromSecret(secret(map[string]string{"private-key": "key", "certificate": "certificate"}), DefaultCredentialKeys, aci.Credentials{Username: "ocpaci"})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should pass rotated credentials to the APIC client", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-credentials"}}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+		credentialsSecret := secret(map[string]string{"password": "rotated"})
+		credentialsSecret.Namespace = namespace.Name
+		Expect(k8sClient.Create(ctx, credentialsSecret)).Should(Succeed())
+
+		setter := &fakeCredentialsSetter{}
+		key := types.NamespacedName{Name: credentialsSecret.Name, Namespace: namespace.Name}
+		reconciler := &CredentialsReconciler{
+			Client:      k8sClient,
+			ApicClient:  setter,
+			Secret:      key,
+			Keys:        DefaultCredentialKeys,
+			Defaults:    aci.Credentials{Username: "ocpaci"},
+			Credentials: aci.Credentials{Username: "ocpaci", Password: "initial"},
+		}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(setter.credentials).Should(Equal([]aci.Credentials{{Username: "ocpaci", Password: "rotated"}}))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(setter.credentials).Should(HaveLen(1))
+	})
+})
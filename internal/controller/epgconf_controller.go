@@ -19,16 +19,27 @@ package controller
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
 	"github.com/4ndersson/epg-config-operator/pkg/aci"
@@ -42,26 +53,71 @@ type EpgconfReconciler struct {
 	Scheme     *runtime.Scheme
 	ApicClient aci.ApicInterface
 	CniConfig  CniConfig
+	Recorder   record.EventRecorder
+
+	// OperatorConfigName is the name of the EpgOperatorConfig, "default"
+	// when empty.
+	OperatorConfigName string
+	// EpgNameTemplate is the naming template used when the EpgOperatorConfig
+	// has none.
+	EpgNameTemplate string
+
+	// ResyncInterval is how often a ready Epgconf is compared with the EPG on
+	// the APIC to detect and repair drift. Zero disables the resync.
+	ResyncInterval time.Duration
+
+	// Connectivity gates the reconciles on the connection to the APIC, they
+	// are not gated when it is nil.
+	Connectivity *ApicConnectivity
+
+	// APIReader reads the Epgconfs sharing an EPG from the API server, the
+	// cache may not have seen an Epgconf that just started to use the EPG
+	// when the last other one is deleted. The cached client is used when nil.
+	APIReader client.Reader
+
+	// configLock guards CniConfig. It is only held to copy or replace the
+	// config, a reconcile works on its own copy.
+	configLock sync.RWMutex
+	// configChanges receives an event when the config changed, it is
+	// created by SetupWithManager.
+	configChanges chan event.GenericEvent
 }
 
 type CniConfig struct {
-	ApicIp             string
-	ApicUsername       string
-	ApicPassword       string
-	ApicPrivateKey     string
-	KeyPath            string
-	Tenant             string
-	BridgeDomain       string
-	VmmDomain          string
-	VmmDomainType      string
-	ApplicationProfile string
-	ProvidedContracts  []string
-	ConsumedContracts  []string
+	ApicHosts      []string
+	ApicUsername   string
+	ApicPassword   string
+	ApicPrivateKey string
+	// ApicCertificateName is the name of the certificate of the APIC user
+	// signing the requests with ApicPrivateKey.
+	ApicCertificateName string
+	Tenant              string
+	BridgeDomain        string
+	VmmDomain           string
+	VmmDomainType       string
+	ApplicationProfile  string
+	Vrf                 string
+	ProvidedContracts   []string
+	ConsumedContracts   []string
+	EpgNameTemplate     string
+	ClusterName         string
+	// ClusterID identifies the cluster in the owner tag of the EPGs, it is
+	// the UID of the kube-system namespace.
+	ClusterID string
+	// DeletionPolicy is used for Epgconfs without a deletion policy.
+	DeletionPolicy epgv1alpha1.DeletionPolicy
+	// The allow-lists restrict the tenants, application profiles, bridge
+	// domains and VMM domains an Epgconf may use, empty allows all.
+	AllowedTenants             []string
+	AllowedApplicationProfiles []string
+	AllowedBridgeDomains       []string
+	AllowedVmmDomains          []string
 }
 
 // +kubebuilder:rbac:groups=epg.custom.aci,resources=epgconfs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=epg.custom.aci,resources=epgconfs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=epg.custom.aci,resources=epgconfs/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -77,22 +133,33 @@ const epgConfFinalizer = "epg.custom.config/finalizer"
 func (r *EpgconfReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	l := log.FromContext(ctx)
 
+	// The reconcile works on a copy of the config, so that it sees the same
+	// config from start to end while the config is reloaded.
+	config := r.Config()
+
 	conf := &epgv1alpha1.Epgconf{}
 	err := r.Get(ctx, req.NamespacedName, conf)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			l.Info("Epg config resource not found. Ignoring since object must be deleted")
+			epgconfStates.record(req.NamespacedName, nil)
 			return ctrl.Result{}, nil
 		}
 		l.Error(err, "Failed to get Epg config resource")
 		return ctrl.Result{}, err
 	}
+	defer func() { epgconfStates.record(req.NamespacedName, conf) }()
+
+	if apicErr := r.Connectivity.Err(); apicErr != nil {
+		return r.apicUnavailable(ctx, conf, apicErr)
+	}
+	setCondition(conf, epgv1alpha1.ConditionApicUnavailable, metav1.ConditionFalse, reasonApicReachable, "APIC is reachable")
 
 	isEpgConfigMarkedToBeDeleted := conf.GetDeletionTimestamp() != nil
 	if isEpgConfigMarkedToBeDeleted {
 		if controllerutil.ContainsFinalizer(conf, epgConfFinalizer) {
-			if err := r.finalizeEpgConf(ctx, l, conf); err != nil {
-				return ctrl.Result{}, err
+			if err := r.finalizeEpgConf(ctx, l, conf, config); err != nil {
+				return resultForError(l, err)
 			}
 
 			controllerutil.RemoveFinalizer(conf, epgConfFinalizer)
@@ -113,99 +180,957 @@ func (r *EpgconfReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	result, err := r.ReconcileEpgConf(ctx, l, conf)
+	reconcileErr := r.ReconcileEpgConf(ctx, l, conf, config)
 
-	if err != nil {
+	conf.Status.ObservedGeneration = conf.GetGeneration()
+	if reconcileErr != nil {
 		conf.Status.State = "Failed"
+		setConditionFalse(conf, epgv1alpha1.ConditionReady, reasonReconcileFailed, reconcileErr)
 		err = r.Status().Update(context.Background(), conf)
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", err)
 		}
-		return result, err
+		return resultForError(l, reconcileErr)
 	}
 
 	conf.Status.State = "Ready"
+	conf.Status.LastApicError = ""
+	setConditionTrue(conf, epgv1alpha1.ConditionReady, reasonReconciled, "EPG is configured on the APIC")
 	err = r.Status().Update(context.Background(), conf)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", err)
 	}
 
+	return ctrl.Result{RequeueAfter: r.ResyncInterval}, nil
+}
+
+// apicUnavailable reports that the Epgconf waits for the APIC. It is
+// reconciled again when the APIC is reachable, finalizers are left in place
+// so that deletions are also retried then.
+func (r *EpgconfReconciler) apicUnavailable(ctx context.Context, conf *epgv1alpha1.Epgconf, apicErr error) (ctrl.Result, error) {
+	if meta.IsStatusConditionTrue(conf.Status.Conditions, epgv1alpha1.ConditionApicUnavailable) {
+		return ctrl.Result{}, nil
+	}
+	message := fmt.Sprintf("APIC is unreachable: %s", apicErr)
+	setConditionTrue(conf, epgv1alpha1.ConditionApicUnavailable, reasonApicUnavailable, message)
+	setCondition(conf, epgv1alpha1.ConditionReady, metav1.ConditionFalse, reasonApicUnavailable, message)
+	err := r.Status().Update(ctx, conf)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", err)
+	}
 	return ctrl.Result{}, nil
 }
 
-func (r *EpgconfReconciler) ReconcileEpgConf(ctx context.Context, l logr.Logger, conf *epgv1alpha1.Epgconf) (ctrl.Result, error) {
-	err := r.ApicClient.CreateEpg(fmt.Sprintf(conf.GetNamespace()+"_EPG"), r.CniConfig.ApplicationProfile, r.CniConfig.Tenant, r.CniConfig.BridgeDomain, r.CniConfig.VmmDomain, r.CniConfig.VmmDomainType) //nolint:govet
+func (r *EpgconfReconciler) ReconcileEpgConf(ctx context.Context, l logr.Logger, conf *epgv1alpha1.Epgconf, config CniConfig) error {
+	epg, err := ResolveEpg(conf, config)
 	if err != nil {
-		l.Error(err, "error occurred while creating epg")
-		return ctrl.Result{}, err
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonInvalidEpgName, err)
+		return reconcile.TerminalError(err)
+	}
+	// The webhook checks the allow-lists too, but an Epgconf may predate them
+	// or have been created with the webhook disabled.
+	if notAllowed := CheckAllowed(epg, config); len(notAllowed) > 0 {
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonNotAllowed, notAllowed[0])
+		return reconcile.TerminalError(notAllowed[0])
+	}
+
+	references, err := r.epgReferences(ctx, conf, epg.Dn())
+	if err != nil {
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonKubernetesError, err)
+		return err
+	}
+	// Like the allow-lists, sharing is checked by the webhook as well. The
+	// status keeps the previous EPG, a refused Epgconf does not count as a
+	// reference of the EPG it asked for.
+	err = checkShared(conf, references)
+	if err != nil {
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonEpgNotShared, err)
+		return reconcile.TerminalError(err)
+	}
+	if conf.Status.EpgDn != "" && conf.Status.EpgDn != epg.Dn() {
+		l.Info(fmt.Sprintf("EPG changed from %s to %s, the old EPG is left on the APIC", conf.Status.EpgDn, epg.Dn()))
+	}
+	conf.Status.EpgName = epg.Name
+	conf.Status.EpgDn = epg.Dn()
+	conf.Status.SharedWith = lo.Map(references, func(ref epgv1alpha1.Epgconf, _ int) string { return ref.GetNamespace() })
+	desiredProvidedContracts, desiredConsumedContracts := sharedDesiredContracts(conf, references, config)
+
+	// With an ESG the contracts of all Epgconfs in it are applied to the ESG.
+	contractsDn := epg.Dn()
+	var esgReferences []epgv1alpha1.Epgconf
+	if conf.Spec.Esg != nil {
+		contractsDn = aci.EsgDn(conf.Spec.Esg.Name, epg.ApplicationProfile, epg.Tenant)
+		esgReferences, err = r.esgReferences(ctx, conf, contractsDn)
+		if err != nil {
+			r.stepFailed(conf, epgv1alpha1.ConditionEsgBound, reasonKubernetesError, err)
+			return err
+		}
+		desiredProvidedContracts, desiredConsumedContracts = sharedDesiredContracts(conf, esgReferences, config)
+	}
+
+	// Only an EPG that has been configured before can drift, the first
+	// reconcile creates it.
+	if meta.IsStatusConditionTrue(conf.Status.Conditions, epgv1alpha1.ConditionReady) {
+		drift, err := r.detectDrift(epg, conf.Status.EpgOwned, contractsDn, desiredProvidedContracts, desiredConsumedContracts, config)
+		if err != nil {
+			l.Error(err, "error occurred while checking the EPG for drift")
+			r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonApicError, err)
+			return err
+		}
+		if len(drift) > 0 {
+			l.Info(fmt.Sprintf("Drift detected on EPG %s, repairing", conf.Status.EpgDn), "drift", drift)
+			setConditionTrue(conf, epgv1alpha1.ConditionDriftDetected, reasonDriftDetected, strings.Join(drift, "; "))
+			r.Recorder.Event(conf, corev1.EventTypeWarning, reasonDriftDetected, fmt.Sprintf("EPG drifted from the desired state: %s", strings.Join(drift, "; ")))
+		} else {
+			setCondition(conf, epgv1alpha1.ConditionDriftDetected, metav1.ConditionFalse, reasonNoDrift, "EPG matches the desired state")
+		}
+	}
+
+	owned, tagged, owner, err := r.epgOwnership(conf, epg, config)
+	if err != nil {
+		l.Error(err, "error occurred while checking the owner of the epg")
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonApicError, err)
+		return err
+	}
+	wasOwned := conf.Status.EpgOwned
+	conf.Status.EpgOwned = owned
+	if !owned && conf.Spec.AdoptionPolicy == epgv1alpha1.AdoptionPolicyFail {
+		err = fmt.Errorf("EPG %s exists on the APIC and was not created by the operator", epg.Name)
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonEpgNotOwned, err)
+		return reconcile.TerminalError(err)
+	}
+	if !owned && owner != "" && conf.Spec.AdoptionPolicy == epgv1alpha1.AdoptionPolicyAdopt {
+		err = fmt.Errorf("EPG %s is owned by cluster %s and can not be adopted", epg.Name, owner)
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonEpgNotOwned, err)
+		return reconcile.TerminalError(err)
+	}
+
+	if conf.Spec.CreateMissingParents {
+		err = r.ensureParents(l, conf, epg, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// An existing EPG the operator does not own is used as it is.
+	if owned {
+		err = r.ApicClient.CreateEpg(epg.Name, epg.ApplicationProfile, epg.Tenant, config.VmmDomainType)
+		if err != nil {
+			l.Error(err, "error occurred while creating epg")
+			r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonApicError, err)
+			return err
+		}
+		if !tagged {
+			err = r.ApicClient.SetEpgTag(epg.Name, epg.ApplicationProfile, epg.Tenant, aci.OwnerTagKey, config.ClusterID)
+			if err != nil {
+				l.Error(err, "error occurred while tagging epg")
+				r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonApicError, err)
+				return err
+			}
+		}
+		// An EPG retained by a deleted Epgconf is no longer orphaned once an
+		// Epgconf manages it again.
+		if !wasOwned {
+			err = r.ApicClient.RemoveEpgTag(epg.Name, epg.ApplicationProfile, epg.Tenant, aci.OrphanedTagKey)
+			if err != nil {
+				l.Error(err, "error occurred while removing orphaned tag from epg")
+				r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonApicError, err)
+				return err
+			}
+		}
+	}
+	r.stepSucceeded(conf, epgv1alpha1.ConditionEpgCreated, fmt.Sprintf("EPG %s exists", conf.Status.EpgDn))
+
+	// The bridge domain and VMM domain of an EPG the operator does not own
+	// are left as they were configured on the APIC.
+	if owned {
+		err = r.ApicClient.BindBridgeDomain(epg.Name, epg.ApplicationProfile, epg.Tenant, epg.BridgeDomain)
+		if err != nil {
+			l.Error(err, "error occurred while binding bridge domain")
+			r.stepFailed(conf, epgv1alpha1.ConditionBridgeDomainBound, reasonApicError, err)
+			return err
+		}
+		r.stepSucceeded(conf, epgv1alpha1.ConditionBridgeDomainBound, fmt.Sprintf("EPG is bound to bridge domain %s", epg.BridgeDomain))
+
+		err = r.ApicClient.BindVmmDomain(epg.Name, epg.ApplicationProfile, epg.Tenant, config.VmmDomain, config.VmmDomainType)
+		if err != nil {
+			l.Error(err, "error occurred while binding vmm domain")
+			r.stepFailed(conf, epgv1alpha1.ConditionVmmDomainBound, reasonApicError, err)
+			return err
+		}
+		r.stepSucceeded(conf, epgv1alpha1.ConditionVmmDomainBound, fmt.Sprintf("EPG is attached to VMM domain %s", config.VmmDomain))
+	} else {
+		meta.RemoveStatusCondition(&conf.Status.Conditions, epgv1alpha1.ConditionBridgeDomainBound)
+		meta.RemoveStatusCondition(&conf.Status.Conditions, epgv1alpha1.ConditionVmmDomainBound)
 	}
 
 	l.Info(fmt.Sprintf("Adds annotation on namespace %s", conf.GetNamespace()))
-	err = r.AnnotateNamespace(ctx, conf.GetNamespace(), r.CniConfig.ApplicationProfile, r.CniConfig.Tenant)
+	err = r.AnnotateNamespace(ctx, conf.GetNamespace(), epg.Name, epg.ApplicationProfile, epg.Tenant)
 	if err != nil {
 		l.Info("error occurred while annotating the namespace: %w", err)
-		return ctrl.Result{}, err
+		r.stepFailed(conf, epgv1alpha1.ConditionNamespaceAnnotated, reasonKubernetesError, err)
+		return err
+	}
+	r.stepSucceeded(conf, epgv1alpha1.ConditionNamespaceAnnotated, fmt.Sprintf("Namespace %s is annotated", conf.GetNamespace()))
+
+	if conf.Spec.Esg != nil {
+		err = r.reconcileEsg(ctx, l, conf, epg, esgReferences, config)
+		if err != nil {
+			r.stepFailed(conf, epgv1alpha1.ConditionEsgBound, reasonApicError, err)
+			return err
+		}
+		r.stepSucceeded(conf, epgv1alpha1.ConditionEsgBound, fmt.Sprintf("EPG is selected by ESG %s", conf.Status.EsgDn))
+	} else if conf.Status.EsgDn != "" {
+		err = r.releaseEsg(ctx, l, conf, epg.Dn(), config)
+		if err != nil {
+			r.stepFailed(conf, epgv1alpha1.ConditionEsgBound, reasonApicError, err)
+			return err
+		}
+		meta.RemoveStatusCondition(&conf.Status.Conditions, epgv1alpha1.ConditionEsgBound)
 	}
 
-	consumedContracts, err := r.ApicClient.GetConsumedContracts(conf.GetNamespace()+"_EPG", r.CniConfig.ApplicationProfile, r.CniConfig.Tenant)
+	// A relation to a contract that does not exist is faulted on the APIC,
+	// missing contracts are left out until they are created.
+	missing, err := r.missingContracts(epg.Tenant, lo.Uniq(append(slices.Clone(desiredProvidedContracts), desiredConsumedContracts...)))
 	if err != nil {
-		return ctrl.Result{}, err
+		r.stepFailed(conf, epgv1alpha1.ConditionContractsSynced, reasonApicError, err)
+		return err
+	}
+	desiredProvidedContracts = lo.Without(desiredProvidedContracts, missing...)
+	desiredConsumedContracts = lo.Without(desiredConsumedContracts, missing...)
+
+	err = r.syncContracts(l, conf, contractsDn, desiredProvidedContracts, desiredConsumedContracts)
+	if err != nil {
+		r.stepFailed(conf, epgv1alpha1.ConditionContractsSynced, reasonApicError, err)
+		return err
+	}
+	if len(missing) > 0 {
+		err = fmt.Errorf("%w: %s not found in tenant %s or common", errContractMissing, strings.Join(missing, ", "), epg.Tenant)
+		setCondition(conf, epgv1alpha1.ConditionContractMissing, metav1.ConditionTrue, reasonContractMissing, err.Error())
+		r.stepFailed(conf, epgv1alpha1.ConditionContractsSynced, reasonContractMissing, err)
+		return err
+	}
+	setCondition(conf, epgv1alpha1.ConditionContractMissing, metav1.ConditionFalse, reasonContractsFound, "All contracts exist on the APIC")
+	r.stepSucceeded(conf, epgv1alpha1.ConditionContractsSynced, "Contracts on the EPG match the desired contracts")
+
+	// Every step succeeded, so the drift found before them has been repaired.
+	if drift := meta.FindStatusCondition(conf.Status.Conditions, epgv1alpha1.ConditionDriftDetected); drift != nil && drift.Status == metav1.ConditionTrue {
+		setCondition(conf, epgv1alpha1.ConditionDriftDetected, metav1.ConditionFalse, reasonDriftRepaired, "Repaired drift: "+drift.Message)
+		r.Recorder.Event(conf, corev1.EventTypeNormal, reasonDriftRepaired, fmt.Sprintf("Repaired drift on EPG %s", conf.Status.EpgDn))
+	}
+
+	return nil
+}
+
+// NotAllowed is a tenant, application profile or bridge domain of an EPG that
+// is not in its allow-list.
+type NotAllowed struct {
+	// Field is the field of the Epgconf spec that sets the value.
+	Field string
+	Kind  string
+	Value string
+}
+
+func (n NotAllowed) Error() string {
+	return fmt.Sprintf("the operator is not allowed to configure %s %s", n.Kind, n.Value)
+}
+
+// CheckAllowed returns the tenant, application profile and bridge domain of
+// the EPG that are not in their allow-list. It is used by the reconciler and
+// the webhook.
+func CheckAllowed(epg Epg, config CniConfig) []NotAllowed {
+	notAllowed := []NotAllowed{}
+	for _, check := range []struct {
+		NotAllowed
+		allowList []string
+	}{
+		{NotAllowed{"tenant", "tenant", epg.Tenant}, config.AllowedTenants},
+		{NotAllowed{"applicationProfile", "application profile", epg.ApplicationProfile}, config.AllowedApplicationProfiles},
+		{NotAllowed{"bridgeDomain", "bridge domain", epg.BridgeDomain}, config.AllowedBridgeDomains},
+	} {
+		if !Allowed(check.allowList, check.Value) {
+			notAllowed = append(notAllowed, check.NotAllowed)
+		}
+	}
+	return notAllowed
+}
+
+// Allowed reports whether the value is in the allow-list, an empty allow-list
+// allows every value.
+func Allowed(allowList []string, value string) bool {
+	return len(allowList) == 0 || lo.Contains(allowList, value)
+}
+
+// ensureParents creates the application profile and the bridge domain of the
+// EPG if they are missing on the APIC.
+func (r *EpgconfReconciler) ensureParents(l logr.Logger, conf *epgv1alpha1.Epgconf, epg Epg, config CniConfig) error {
+	created, err := r.ApicClient.EnsureApplicationProfile(epg.ApplicationProfile, epg.Tenant)
+	if err != nil {
+		l.Error(err, "error occurred while creating application profile")
+		r.stepFailed(conf, epgv1alpha1.ConditionEpgCreated, reasonApicError, err)
+		return err
+	}
+	if created {
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "ApplicationProfileCreated", "Created application profile %s", aci.ApplicationProfileDn(epg.ApplicationProfile, epg.Tenant))
+	}
+
+	vrf := lo.Ternary(conf.Spec.Vrf != "", conf.Spec.Vrf, config.Vrf)
+	created, err = r.ApicClient.EnsureBridgeDomain(epg.BridgeDomain, epg.Tenant, vrf, conf.Spec.Subnets)
+	if err != nil {
+		l.Error(err, "error occurred while creating bridge domain")
+		r.stepFailed(conf, epgv1alpha1.ConditionBridgeDomainBound, reasonApicError, err)
+		return err
+	}
+	if created {
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "BridgeDomainCreated", "Created bridge domain %s in VRF %s", aci.BridgeDomainDn(epg.BridgeDomain, epg.Tenant), vrf)
+	}
+	return nil
+}
+
+// epgOwnership decides from the owner tag and the adoption policy if the
+// operator owns the EPG, and reports if the EPG already carries the owner tag
+// of this cluster and the owner tag it carries. A missing EPG is owned once
+// the operator creates it. An EPG owned by another cluster is not adopted.
+func (r *EpgconfReconciler) epgOwnership(conf *epgv1alpha1.Epgconf, epg Epg, config CniConfig) (bool, bool, string, error) {
+	exists, err := r.ApicClient.EpgExists(epg.Name, epg.ApplicationProfile, epg.Tenant)
+	if err != nil {
+		return false, false, "", err
+	}
+	if !exists {
+		return true, false, "", nil
 	}
 
-	_, diffConsumedContracts := lo.Difference(consumedContracts, r.CniConfig.ConsumedContracts)
+	owned, owner, err := r.ownsEpg(epg, config)
+	if err != nil {
+		return false, false, "", err
+	}
+	if owned {
+		return true, owner == config.ClusterID, owner, nil
+	}
+
+	if conf.Spec.AdoptionPolicy == epgv1alpha1.AdoptionPolicyAdopt && owner == "" {
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "EpgAdopted", "Adopted existing EPG %s", epg.Name)
+		return true, false, "", nil
+	}
+	return false, false, owner, nil
+}
+
+// ownsEpg reports if the EPG carries the owner tag of this cluster and returns
+// the owner tag. Releases of the operator before the owner tag left their
+// EPGs untagged, an untagged EPG with the annotation and description the
+// operator sets is owned so that it is tagged and managed as before.
+func (r *EpgconfReconciler) ownsEpg(epg Epg, config CniConfig) (bool, string, error) {
+	owner, err := r.ApicClient.GetEpgTag(epg.Name, epg.ApplicationProfile, epg.Tenant, aci.OwnerTagKey)
+	if err != nil {
+		return false, "", err
+	}
+	if owner != "" {
+		return owner == config.ClusterID, owner, nil
+	}
+
+	current, err := r.ApicClient.ReadEpg(epg.Name, epg.ApplicationProfile, epg.Tenant)
+	if err != nil || current == nil {
+		return false, "", err
+	}
+	return current.Annotation == aci.EpgAnnotation(config.VmmDomainType) && current.Description == aci.EpgDescription, "", nil
+}
+
+// detectDrift compares the EPG on the APIC with the desired state and returns
+// a description of every difference found. Like syncContracts it reports the
+// desired contracts that are missing and the contracts the operator added that
+// are no longer desired. The domains of an EPG that is not owned are not
+// compared, the operator does not configure them.
+func (r *EpgconfReconciler) detectDrift(epg Epg, owned bool, contractsDn string, desiredProvidedContracts, desiredConsumedContracts []string, config CniConfig) ([]string, error) {
+	current, err := r.ApicClient.ReadEpg(epg.Name, epg.ApplicationProfile, epg.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return []string{"EPG is missing"}, nil
+	}
+
+	drift := []string{}
+	if owned && current.BridgeDomain != epg.BridgeDomain {
+		drift = append(drift, fmt.Sprintf("bridge domain is %q, expected %q", current.BridgeDomain, epg.BridgeDomain))
+	}
+	vmmDomainDn := aci.VmmDomainDn(config.VmmDomain, config.VmmDomainType)
+	if owned && !lo.Contains(current.VmmDomains, vmmDomainDn) {
+		drift = append(drift, fmt.Sprintf("VMM domain %s is not attached", vmmDomainDn))
+	}
+
+	consumedContracts, err := r.ApicClient.GetConsumedContracts(contractsDn)
+	if err != nil {
+		return nil, err
+	}
+	_, missingConsumedContracts := lo.Difference(consumedContracts, desiredConsumedContracts)
+	if len(missingConsumedContracts) > 0 {
+		drift = append(drift, fmt.Sprintf("consumed contracts %v are missing", missingConsumedContracts))
+	}
+	managedConsumedContracts, err := r.ApicClient.GetManagedConsumedContracts(contractsDn)
+	if err != nil {
+		return nil, err
+	}
+	extraConsumedContracts, _ := lo.Difference(managedConsumedContracts, desiredConsumedContracts)
+	if len(extraConsumedContracts) > 0 {
+		drift = append(drift, fmt.Sprintf("consumed contracts %v are not desired", extraConsumedContracts))
+	}
+
+	providedContracts, err := r.ApicClient.GetProvidedContracts(contractsDn)
+	if err != nil {
+		return nil, err
+	}
+	_, missingProvidedContracts := lo.Difference(providedContracts, desiredProvidedContracts)
+	if len(missingProvidedContracts) > 0 {
+		drift = append(drift, fmt.Sprintf("provided contracts %v are missing", missingProvidedContracts))
+	}
+	managedProvidedContracts, err := r.ApicClient.GetManagedProvidedContracts(contractsDn)
+	if err != nil {
+		return nil, err
+	}
+	extraProvidedContracts, _ := lo.Difference(managedProvidedContracts, desiredProvidedContracts)
+	if len(extraProvidedContracts) > 0 {
+		drift = append(drift, fmt.Sprintf("provided contracts %v are not desired", extraProvidedContracts))
+	}
+
+	return drift, nil
+}
+
+// missingContracts returns the contracts that resolve neither in the tenant
+// nor in common.
+func (r *EpgconfReconciler) missingContracts(tenant string, contracts []string) ([]string, error) {
+	missing := []string{}
+	for _, contract := range contracts {
+		exists, err := r.ApicClient.ContractExists(tenant, contract)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, contract)
+		}
+	}
+	return missing, nil
+}
+
+// syncContracts adds the desired contracts missing on the EPG or ESG and
+// removes the stale ones.
+func (r *EpgconfReconciler) syncContracts(l logr.Logger, conf *epgv1alpha1.Epgconf, dn string, desiredProvidedContracts, desiredConsumedContracts []string) error {
+	consumedContracts, err := r.ApicClient.GetConsumedContracts(dn)
+	if err != nil {
+		return err
+	}
+
+	_, diffConsumedContracts := lo.Difference(consumedContracts, desiredConsumedContracts)
 
 	l.Info(fmt.Sprintf("Consume contracts for EPG %s", conf.Name))
 	for _, contract := range diffConsumedContracts {
-		err = r.ApicClient.ConsumeContract(conf.GetNamespace()+"_EPG", r.CniConfig.ApplicationProfile, r.CniConfig.Tenant, contract)
+		err = r.ApicClient.ConsumeContract(dn, contract)
 		if err != nil {
 			l.Info("error occurred while consuming contract: %w", err)
-			return ctrl.Result{}, err
+			return err
 		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "ContractConsumed", "EPG consumes contract %s", contract)
 	}
 
-	providedContracts, err := r.ApicClient.GetProvidedContracts(conf.GetNamespace()+"_EPG", r.CniConfig.ApplicationProfile, r.CniConfig.Tenant)
+	providedContracts, err := r.ApicClient.GetProvidedContracts(dn)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 
-	_, diffProvidedContracts := lo.Difference(providedContracts, r.CniConfig.ProvidedContracts)
+	_, diffProvidedContracts := lo.Difference(providedContracts, desiredProvidedContracts)
 
 	l.Info(fmt.Sprintf("Provide contracts for EPG %s", conf.Name))
 	for _, contract := range diffProvidedContracts {
-		err = r.ApicClient.ProvideContract(conf.GetNamespace()+"_EPG", r.CniConfig.ApplicationProfile, r.CniConfig.Tenant, contract)
+		err = r.ApicClient.ProvideContract(dn, contract)
 		if err != nil {
 			l.Info("error occurred while providing contract: %w", err)
-			return ctrl.Result{}, err
+			return err
 		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "ContractProvided", "EPG provides contract %s", contract)
 	}
 
-	return ctrl.Result{}, nil
+	return r.removeStaleContracts(l, conf, dn, desiredProvidedContracts, desiredConsumedContracts)
+}
+
+// removeStaleContracts deletes the contract relations the operator has created
+// on the EPG or ESG that are no longer desired. Relations added by hand on the APIC
+// do not carry the operator annotation and are kept.
+func (r *EpgconfReconciler) removeStaleContracts(l logr.Logger, conf *epgv1alpha1.Epgconf, dn string, desiredProvided, desiredConsumed []string) error {
+	managedConsumedContracts, err := r.ApicClient.GetManagedConsumedContracts(dn)
+	if err != nil {
+		return err
+	}
+
+	staleConsumedContracts, _ := lo.Difference(managedConsumedContracts, desiredConsumed)
+	for _, contract := range staleConsumedContracts {
+		l.Info(fmt.Sprintf("Removing consumed contract %s from %s", contract, dn))
+		err = r.ApicClient.RemoveConsumedContract(dn, contract)
+		if err != nil {
+			l.Error(err, "error occurred while removing consumed contract")
+			return err
+		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "ContractRemoved", "Removed consumed contract %s from %s", contract, dn)
+	}
+
+	managedProvidedContracts, err := r.ApicClient.GetManagedProvidedContracts(dn)
+	if err != nil {
+		return err
+	}
+
+	staleProvidedContracts, _ := lo.Difference(managedProvidedContracts, desiredProvided)
+	for _, contract := range staleProvidedContracts {
+		l.Info(fmt.Sprintf("Removing provided contract %s from %s", contract, dn))
+		err = r.ApicClient.RemoveProvidedContract(dn, contract)
+		if err != nil {
+			l.Error(err, "error occurred while removing provided contract")
+			return err
+		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "ContractRemoved", "Removed provided contract %s from %s", contract, dn)
+	}
+
+	return nil
+}
+
+// desiredContracts returns the provided and consumed contracts for the Epgconf,
+// combining the spec with the default contracts according to its contract mode.
+func desiredContracts(conf *epgv1alpha1.Epgconf, config CniConfig) ([]string, []string) {
+	if conf.Spec.ContractMode == epgv1alpha1.ContractModeReplace {
+		return conf.Spec.ProvidedContracts, conf.Spec.ConsumedContracts
+	}
+	return lo.Union(config.ProvidedContracts, conf.Spec.ProvidedContracts),
+		lo.Union(config.ConsumedContracts, conf.Spec.ConsumedContracts)
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Every Epgconf is
+// enqueued when SetCniConfig changes the config, and when the APIC becomes
+// unreachable or reachable again.
 func (r *EpgconfReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.configLock.Lock()
+	r.configChanges = make(chan event.GenericEvent, 1)
+	r.configLock.Unlock()
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&epgv1alpha1.Epgconf{}).
-		Complete(r)
+		WatchesRawSource(&source.Channel{Source: r.configChanges},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+				return r.allEpgconfs(ctx)
+			}))
+	if r.Connectivity != nil {
+		b = b.WatchesRawSource(&source.Channel{Source: r.Connectivity.Changes()},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+				return r.allEpgconfs(ctx)
+			}))
+	}
+	return b.Complete(r)
+}
+
+func (r *EpgconfReconciler) operatorConfigName() string {
+	return lo.Ternary(r.OperatorConfigName != "", r.OperatorConfigName, DefaultOperatorConfigName)
 }
 
-func (r *EpgconfReconciler) finalizeEpgConf(ctx context.Context, l logr.Logger, c *epgv1alpha1.Epgconf) error {
-	l.Info(fmt.Sprintf("Deleting EPG  %s", c.GetNamespace()+"_EPG"))
-	err := r.ApicClient.DeleteEpg(c.GetNamespace()+"_EPG", r.CniConfig.ApplicationProfile, r.CniConfig.Tenant)
+func isCniConfigMap(o client.Object) bool {
+	return o.GetNamespace() == AciContainersNamespace &&
+		(o.GetName() == AciContainersConfigName || o.GetName() == DefaultContractsConfigName)
+}
 
+// allEpgconfs returns a request for every Epgconf.
+func (r *EpgconfReconciler) allEpgconfs(ctx context.Context) []reconcile.Request {
+	confs := &epgv1alpha1.EpgconfList{}
+	err := r.List(ctx, confs)
 	if err != nil {
-		return fmt.Errorf("error occurred while deleting EPG: %w", err)
+		log.FromContext(ctx).Error(err, "error occurred while listing Epgconf resources")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(confs.Items))
+	for i, conf := range confs.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}}
+	}
+	return requests
+}
+
+// Config returns a copy of the CniConfig currently used by the reconciler.
+func (r *EpgconfReconciler) Config() CniConfig {
+	r.configLock.RLock()
+	defer r.configLock.RUnlock()
+	return r.CniConfig
+}
+
+// ReloadCniConfig reads the EpgOperatorConfig and the ConfigMaps again and
+// applies them with SetCniConfig.
+func (r *EpgconfReconciler) ReloadCniConfig(ctx context.Context) error {
+	cniConfig, _, _, err := LoadCniConfig(ctx, r.Client, r.operatorConfigName())
+	if err != nil {
+		return err
+	}
+	return r.SetCniConfig(ctx, cniConfig)
+}
+
+// SetCniConfig swaps in the config loaded from the EpgOperatorConfig and the
+// ConfigMaps if it is valid, and enqueues every Epgconf when it changed so the
+// new config rolls out to all namespaces. The APIC connection is kept,
+// changing it needs a restart of the manager. The credentials are rotated
+// through their Secret.
+func (r *EpgconfReconciler) SetCniConfig(ctx context.Context, cniConfig CniConfig) error {
+	if cniConfig.EpgNameTemplate == "" {
+		cniConfig.EpgNameTemplate = r.EpgNameTemplate
+	}
+
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+
+	if !slices.Equal(cniConfig.ApicHosts, r.CniConfig.ApicHosts) || cniConfig.ApicUsername != r.CniConfig.ApicUsername {
+		log.FromContext(ctx).Info("APIC connection settings changed, restart the manager to apply them")
+	}
+	cniConfig.ApicHosts = r.CniConfig.ApicHosts
+	cniConfig.ApicUsername = r.CniConfig.ApicUsername
+	cniConfig.ApicPassword = r.CniConfig.ApicPassword
+	cniConfig.ApicPrivateKey = r.CniConfig.ApicPrivateKey
+	cniConfig.ApicCertificateName = r.CniConfig.ApicCertificateName
+	cniConfig.ClusterName = r.CniConfig.ClusterName
+	cniConfig.ClusterID = r.CniConfig.ClusterID
+	cniConfig.DeletionPolicy = r.CniConfig.DeletionPolicy
+	cniConfig.AllowedTenants = r.CniConfig.AllowedTenants
+	cniConfig.AllowedApplicationProfiles = r.CniConfig.AllowedApplicationProfiles
+	cniConfig.AllowedBridgeDomains = r.CniConfig.AllowedBridgeDomains
+	cniConfig.AllowedVmmDomains = r.CniConfig.AllowedVmmDomains
+	err := ValidateCniConfig(cniConfig)
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(cniConfig, r.CniConfig) {
+		return nil
+	}
+	r.CniConfig = cniConfig
+
+	if r.configChanges != nil {
+		select {
+		case r.configChanges <- event.GenericEvent{Object: &epgv1alpha1.Epgconf{}}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (r *EpgconfReconciler) finalizeEpgConf(ctx context.Context, l logr.Logger, c *epgv1alpha1.Epgconf, config CniConfig) error {
+	// The EPG is deleted where it was created, the template or the spec may
+	// have changed since.
+	var epg Epg
+	if c.Status.EpgDn != "" {
+		name, app, tenant, err := aci.ParseEpgDn(c.Status.EpgDn)
+		if err != nil {
+			return err
+		}
+		epg = Epg{Name: name, ApplicationProfile: app, Tenant: tenant}
+	} else {
+		var err error
+		epg, err = ResolveEpg(c, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Status.EsgDn != "" {
+		err := r.releaseEsg(ctx, l, c, epg.Dn(), config)
+		if err != nil {
+			return fmt.Errorf("error occurred while releasing ESG: %w", err)
+		}
+	}
+
+	references, err := r.epgReferences(ctx, c, epg.Dn())
+	if err != nil {
+		return err
+	}
+	deletionPolicy := deletionPolicy(c, config)
+	if len(references) > 0 {
+		// The EPG is still used by other namespaces, only the contracts this
+		// Epgconf alone asked for are removed.
+		l.Info(fmt.Sprintf("Keeping shared EPG %s, it is used by %d other Epgconfs", epg.Name, len(references)))
+		desiredProvidedContracts, desiredConsumedContracts := sharedDesiredContracts(nil, references, config)
+		err = r.removeStaleContracts(l, c, epg.Dn(), desiredProvidedContracts, desiredConsumedContracts)
+		if err != nil {
+			return fmt.Errorf("error occurred while removing contracts from shared EPG: %w", err)
+		}
+		r.Recorder.Eventf(c, corev1.EventTypeNormal, "EpgRetained", "Kept EPG %s, it is still used by namespaces %v",
+			epg.Name, lo.Map(references, func(ref epgv1alpha1.Epgconf, _ int) string { return ref.GetNamespace() }))
+	} else if owned, _, err := r.ownsEpg(epg, config); err != nil {
+		return fmt.Errorf("error occurred while checking the owner of EPG: %w", err)
+	} else if !owned {
+		// Only the contracts the operator added are removed from an EPG it
+		// did not create.
+		l.Info(fmt.Sprintf("Keeping EPG %s, it was not created by the operator", epg.Name))
+		err = r.removeStaleContracts(l, c, epg.Dn(), []string{}, []string{})
+		if err != nil {
+			return fmt.Errorf("error occurred while removing contracts from EPG: %w", err)
+		}
+		r.Recorder.Eventf(c, corev1.EventTypeNormal, "EpgRetained", "Kept EPG %s, it was not created by the operator", epg.Name)
+	} else if deletionPolicy != epgv1alpha1.DeletionPolicyDelete {
+		return r.orphanEpg(l, c, epg, deletionPolicy)
+	} else {
+		l.Info(fmt.Sprintf("Deleting EPG  %s", epg.Name))
+		err = r.ApicClient.DeleteEpg(epg.Name, epg.ApplicationProfile, epg.Tenant)
+		if err != nil {
+			r.Recorder.Eventf(c, corev1.EventTypeWarning, "EpgDeleteFailed", "Failed to delete EPG: %s", err)
+			return fmt.Errorf("error occurred while deleting EPG: %w", err)
+		}
+		r.Recorder.Eventf(c, corev1.EventTypeNormal, "EpgDeleted", "Deleted EPG %s", epg.Name)
 	}
 
 	err = r.RemoveAnnotationNamespace(ctx, c.GetNamespace())
 	if err != nil {
+		r.Recorder.Eventf(c, corev1.EventTypeWarning, "NamespaceAnnotationRemoveFailed", "Failed to remove annotation from namespace: %s", err)
 		return fmt.Errorf("error occurred while deleting annotation on namespace: %w", err)
 	}
+	r.Recorder.Eventf(c, corev1.EventTypeNormal, "NamespaceAnnotationRemoved", "Removed EPG annotation from namespace %s", c.GetNamespace())
+	return nil
+}
+
+// orphanEpg keeps the EPG of a deleted Epgconf on the APIC and tags it as
+// orphaned. The namespace keeps its annotation so the pods stay in the EPG,
+// with OrphanContractsOnly the contracts the operator added are removed.
+func (r *EpgconfReconciler) orphanEpg(l logr.Logger, c *epgv1alpha1.Epgconf, epg Epg, deletionPolicy epgv1alpha1.DeletionPolicy) error {
+	if deletionPolicy == epgv1alpha1.DeletionPolicyOrphanContractsOnly {
+		err := r.removeStaleContracts(l, c, epg.Dn(), []string{}, []string{})
+		if err != nil {
+			return fmt.Errorf("error occurred while removing contracts from EPG: %w", err)
+		}
+	}
+
+	orphaned := fmt.Sprintf("%s/%s", c.GetNamespace(), c.GetName())
+	err := r.ApicClient.SetEpgTag(epg.Name, epg.ApplicationProfile, epg.Tenant, aci.OrphanedTagKey, orphaned)
+	if err != nil {
+		return fmt.Errorf("error occurred while tagging EPG as orphaned: %w", err)
+	}
+	l.Info(fmt.Sprintf("Keeping EPG %s, deletion policy is %s", epg.Name, deletionPolicy))
+	r.Recorder.Eventf(c, corev1.EventTypeNormal, "EpgRetained", "Kept EPG %s as orphaned, deletion policy is %s", epg.Name, deletionPolicy)
+	return nil
+}
+
+// deletionPolicy returns the deletion policy of the Epgconf, or the operator
+// default if the spec has none.
+func deletionPolicy(conf *epgv1alpha1.Epgconf, config CniConfig) epgv1alpha1.DeletionPolicy {
+	if conf.Spec.DeletionPolicy != "" {
+		return conf.Spec.DeletionPolicy
+	}
+	if config.DeletionPolicy != "" {
+		return config.DeletionPolicy
+	}
+	return epgv1alpha1.DeletionPolicyDelete
+}
+
+// reconcileEsg puts the EPG into the ESG of the spec, creating the ESG if
+// asked to, and moves the contracts the operator added on the EPG to the ESG.
+func (r *EpgconfReconciler) reconcileEsg(ctx context.Context, l logr.Logger, conf *epgv1alpha1.Epgconf, epg Epg, references []epgv1alpha1.Epgconf, config CniConfig) error {
+	esg := conf.Spec.Esg
+	esgDn := aci.EsgDn(esg.Name, epg.ApplicationProfile, epg.Tenant)
+	if conf.Status.EsgDn != "" && conf.Status.EsgDn != esgDn {
+		l.Info(fmt.Sprintf("ESG changed from %s to %s", conf.Status.EsgDn, esgDn))
+		err := r.releaseEsg(ctx, l, conf, epg.Dn(), config)
+		if err != nil {
+			return err
+		}
+	}
+
+	exists, err := r.ApicClient.EsgExists(esg.Name, epg.ApplicationProfile, epg.Tenant)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if !esg.Create {
+			return fmt.Errorf("ESG %s does not exist on the APIC", esgDn)
+		}
+		vrf := lo.Ternary(esg.Vrf != "", esg.Vrf, config.Vrf)
+		err = r.ApicClient.CreateEsg(esg.Name, epg.ApplicationProfile, epg.Tenant, vrf)
+		if err != nil {
+			return err
+		}
+		conf.Status.EsgOwned = true
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "EsgCreated", "Created ESG %s in VRF %s", esgDn, vrf)
+	} else if conf.Status.EsgDn != esgDn {
+		// An Epgconf joining an ESG created by the operator shares its
+		// ownership, the last one to leave deletes it.
+		conf.Status.EsgOwned = lo.SomeBy(references, func(ref epgv1alpha1.Epgconf) bool { return ref.Status.EsgOwned })
+	}
+	conf.Status.EsgDn = esgDn
+
+	err = r.ApicClient.AddEpgSelector(esg.Name, epg.ApplicationProfile, epg.Tenant, epg.Dn())
+	if err != nil {
+		return err
+	}
+	err = r.syncTagSelectors(l, conf, esgDn, r.desiredTagSelectors(conf, references))
+	if err != nil {
+		return err
+	}
+	return r.removeStaleContracts(l, conf, epg.Dn(), []string{}, []string{})
+}
+
+// releaseEsg takes the EPG out of the ESG in the status. The ESG is deleted if
+// the operator created it and no other Epgconf uses it, otherwise only the
+// selectors and contracts no other Epgconf asks for are removed.
+func (r *EpgconfReconciler) releaseEsg(ctx context.Context, l logr.Logger, conf *epgv1alpha1.Epgconf, epgDn string, config CniConfig) error {
+	esgDn := conf.Status.EsgDn
+	name, app, tenant, err := aci.ParseEsgDn(esgDn)
+	if err != nil {
+		return err
+	}
+	references, err := r.esgReferences(ctx, conf, esgDn)
+	if err != nil {
+		return err
+	}
+
+	if len(references) == 0 && conf.Status.EsgOwned {
+		l.Info(fmt.Sprintf("Deleting ESG %s", esgDn))
+		err = r.ApicClient.DeleteEsg(name, app, tenant)
+		if err != nil {
+			return err
+		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "EsgDeleted", "Deleted ESG %s", esgDn)
+	} else {
+		// A shared EPG stays in the ESG while another Epgconf still uses it.
+		if !lo.SomeBy(references, func(ref epgv1alpha1.Epgconf) bool { return ref.Status.EpgDn == epgDn }) {
+			err = r.ApicClient.RemoveEpgSelector(name, app, tenant, epgDn)
+			if err != nil {
+				return err
+			}
+		}
+		err = r.syncTagSelectors(l, conf, esgDn, r.desiredTagSelectors(nil, references))
+		if err != nil {
+			return err
+		}
+		desiredProvidedContracts, desiredConsumedContracts := sharedDesiredContracts(nil, references, config)
+		err = r.removeStaleContracts(l, conf, esgDn, desiredProvidedContracts, desiredConsumedContracts)
+		if err != nil {
+			return err
+		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "EsgReleased", "Removed EPG %s from ESG %s", epgDn, esgDn)
+	}
+
+	conf.Status.EsgDn = ""
+	conf.Status.EsgOwned = false
 	return nil
 }
 
-func (r *EpgconfReconciler) AnnotateNamespace(ctx context.Context, nsName, app, tenant string) error {
-	dnJson := fmt.Sprintf(`{\"tenant\":\"%s\",\"app-profile\":\"%s\",\"name\":\"%s_EPG\"}`, tenant, app, nsName)
+// syncTagSelectors adds the desired tag selectors missing on the ESG and
+// removes the ones the operator added that are no longer desired.
+func (r *EpgconfReconciler) syncTagSelectors(l logr.Logger, conf *epgv1alpha1.Epgconf, esgDn string, desired []aci.TagSelector) error {
+	name, app, tenant, err := aci.ParseEsgDn(esgDn)
+	if err != nil {
+		return err
+	}
+	managed, err := r.ApicClient.GetManagedTagSelectors(name, app, tenant)
+	if err != nil {
+		return err
+	}
+
+	stale, missing := lo.Difference(managed, desired)
+	for _, selector := range missing {
+		err = r.ApicClient.AddTagSelector(name, app, tenant, selector)
+		if err != nil {
+			return err
+		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "TagSelectorAdded", "Added tag selector %s=%s to ESG", selector.Key, selector.Value)
+	}
+	for _, selector := range stale {
+		l.Info(fmt.Sprintf("Removing tag selector %s=%s from ESG %s", selector.Key, selector.Value, esgDn))
+		err = r.ApicClient.RemoveTagSelector(name, app, tenant, selector)
+		if err != nil {
+			return err
+		}
+		r.Recorder.Eventf(conf, corev1.EventTypeNormal, "TagSelectorRemoved", "Removed tag selector %s=%s from ESG", selector.Key, selector.Value)
+	}
+	return nil
+}
+
+// desiredTagSelectors returns the tag selectors of the Epgconf, which may be
+// nil, and of the other Epgconfs in the same ESG.
+func (r *EpgconfReconciler) desiredTagSelectors(conf *epgv1alpha1.Epgconf, references []epgv1alpha1.Epgconf) []aci.TagSelector {
+	selectors := []aci.TagSelector{}
+	if conf != nil {
+		references = append([]epgv1alpha1.Epgconf{*conf}, references...)
+	}
+	for _, ref := range references {
+		if ref.Spec.Esg == nil {
+			continue
+		}
+		for _, selector := range ref.Spec.Esg.TagSelectors {
+			selectors = append(selectors, aci.TagSelector{Key: selector.Key, Value: selector.Value})
+		}
+	}
+	return lo.Uniq(selectors)
+}
+
+// esgReferences returns the other Epgconfs whose EPG is in the ESG.
+func (r *EpgconfReconciler) esgReferences(ctx context.Context, conf *epgv1alpha1.Epgconf, esgDn string) ([]epgv1alpha1.Epgconf, error) {
+	confs := &epgv1alpha1.EpgconfList{}
+	err := r.List(ctx, confs)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(confs.Items, func(other epgv1alpha1.Epgconf, _ int) bool {
+		return other.GetUID() != conf.GetUID() && other.GetDeletionTimestamp() == nil && other.Status.EsgDn == esgDn
+	}), nil
+}
+
+// epgReferences returns the other Epgconfs that use the EPG and are not being
+// deleted. They are the reference count of a shared EPG, so they are read
+// from the API server rather than the cache.
+func (r *EpgconfReconciler) epgReferences(ctx context.Context, conf *epgv1alpha1.Epgconf, epgDn string) ([]epgv1alpha1.Epgconf, error) {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	confs := &epgv1alpha1.EpgconfList{}
+	err := reader.List(ctx, confs)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(confs.Items, func(other epgv1alpha1.Epgconf, _ int) bool {
+		return other.GetUID() != conf.GetUID() && other.GetDeletionTimestamp() == nil && other.Status.EpgDn == epgDn
+	}), nil
+}
+
+// checkShared refuses an EPG used by an Epgconf in another namespace, unless
+// both are shared.
+func checkShared(conf *epgv1alpha1.Epgconf, references []epgv1alpha1.Epgconf) error {
+	for _, ref := range references {
+		if ref.GetNamespace() != conf.GetNamespace() && !(conf.Spec.Shared && ref.Spec.Shared) {
+			return fmt.Errorf("EPG %s is used by namespace %s, both Epgconfs must be shared", ref.Status.EpgName, ref.GetNamespace())
+		}
+	}
+	return nil
+}
+
+// sharedDesiredContracts combines the desired contracts of the Epgconf, if
+// any, with the ones of the other Epgconfs using the same EPG.
+func sharedDesiredContracts(conf *epgv1alpha1.Epgconf, references []epgv1alpha1.Epgconf, config CniConfig) ([]string, []string) {
+	provided, consumed := []string{}, []string{}
+	if conf != nil {
+		provided, consumed = desiredContracts(conf, config)
+	}
+	for i := range references {
+		refProvided, refConsumed := desiredContracts(&references[i], config)
+		provided = lo.Union(provided, refProvided)
+		consumed = lo.Union(consumed, refConsumed)
+	}
+	return provided, consumed
+}
+
+// stepSucceeded marks the condition of a reconcile step as true and emits an
+// event the first time the step succeeds.
+func (r *EpgconfReconciler) stepSucceeded(conf *epgv1alpha1.Epgconf, conditionType, message string) {
+	if setConditionTrue(conf, conditionType, reasonSucceeded, message) {
+		r.Recorder.Event(conf, corev1.EventTypeNormal, conditionType, message)
+	}
+}
+
+// stepFailed marks the condition of a reconcile step as false and emits a
+// warning event with the error.
+func (r *EpgconfReconciler) stepFailed(conf *epgv1alpha1.Epgconf, conditionType, reason string, err error) {
+	setConditionFalse(conf, conditionType, reason, err)
+	r.Recorder.Event(conf, corev1.EventTypeWarning, conditionType+"Failed", err.Error())
+}
+
+func (r *EpgconfReconciler) AnnotateNamespace(ctx context.Context, nsName, epgName, app, tenant string) error {
+	dnJson := fmt.Sprintf(`{\"tenant\":\"%s\",\"app-profile\":\"%s\",\"name\":\"%s\"}`, tenant, app, epgName)
 	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{"opflex.cisco.com/endpoint-group": "%s"}}}`, dnJson))
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
@@ -23,12 +23,15 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
 	"github.com/4ndersson/epg-config-operator/pkg/aci"
@@ -83,6 +86,7 @@ var _ = Describe("Epgconf Controller", func() {
 					Scheme:     k8sClient.Scheme(),
 					ApicClient: apicClient,
 					CniConfig:  cniConf,
+					Recorder:   record.NewFakeRecorder(1024),
 				}
 				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
 				Expect(err).ShouldNot(HaveOccurred())
@@ -112,13 +116,30 @@ var _ = Describe("Epgconf Controller", func() {
 				Expect(epg.Bd).Should(Equal(cniConf.BridgeDomain))
 			})
 			By("Checking consumed contracts", func() {
-				contracts, _ := apicClient.GetConsumedContracts(conf.ObjectMeta.Namespace+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+				contracts, _ := apicClient.GetConsumedContracts(aci.EpgDn(conf.ObjectMeta.Namespace+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
 				Expect(contracts).Should(Equal(cniConf.ConsumedContracts))
 			})
 			By("Checking provided contracts", func() {
-				contracts, _ := apicClient.GetProvidedContracts(conf.ObjectMeta.Namespace+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+				contracts, _ := apicClient.GetProvidedContracts(aci.EpgDn(conf.ObjectMeta.Namespace+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
 				Expect(contracts).Should(Equal(cniConf.ProvidedContracts))
 			})
+			By("Checking the status conditions", func() {
+				updated := &v1alpha1.Epgconf{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}, updated)).Should(Succeed())
+				Expect(updated.Status.State).Should(Equal("Ready"))
+				Expect(updated.Status.EpgDn).Should(Equal(aci.EpgDn(conf.Namespace+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant)))
+				Expect(updated.Status.ObservedGeneration).Should(Equal(updated.Generation))
+				for _, conditionType := range []string{
+					v1alpha1.ConditionEpgCreated,
+					v1alpha1.ConditionBridgeDomainBound,
+					v1alpha1.ConditionVmmDomainBound,
+					v1alpha1.ConditionNamespaceAnnotated,
+					v1alpha1.ConditionContractsSynced,
+					v1alpha1.ConditionReady,
+				} {
+					Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, conditionType)).Should(BeTrue(), conditionType)
+				}
+			})
 		})
 	})
 	Context("When deleting the EpgConf resource", func() {
@@ -131,6 +152,7 @@ var _ = Describe("Epgconf Controller", func() {
 					Scheme:     k8sClient.Scheme(),
 					ApicClient: apicClient,
 					CniConfig:  cniConf,
+					Recorder:   record.NewFakeRecorder(1024),
 				}
 				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}})
 				Expect(err).ShouldNot(HaveOccurred())
@@ -160,3 +182,623 @@ var _ = Describe("Epgconf Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Epgconf Controller with contracts in spec", func() {
+	ctx := context.Background()
+
+	It("Should merge or replace the default contracts", func() {
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "ns-contracts",
+			},
+		}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "epg-contracts-test",
+				Namespace: namespace.Name,
+			},
+			Spec: v1alpha1.EpgconfSpec{
+				ProvidedContracts: []string{"team-provided"},
+				ConsumedContracts: []string{"team-consumed"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+
+		recorder := record.NewFakeRecorder(1024)
+		reconciler := &EpgconfReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			CniConfig:  cniConf,
+			Recorder:   recorder,
+		}
+		lookupKey := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+
+		By("Merging the spec contracts with the defaults", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(consumed).Should(ConsistOf("consumed-contract", "team-consumed"))
+			provided, _ := apicClient.GetProvidedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(provided).Should(ConsistOf("provided-contract", "team-provided"))
+
+			Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Ready"))).Should(BeNumerically(">=", 1))
+			Expect(testutil.ToFloat64(managedEpgs)).Should(BeNumerically(">=", 1))
+
+			Eventually(recorder.Events).Should(Receive(ContainSubstring("EpgCreated")))
+			Eventually(recorder.Events).Should(Receive(ContainSubstring("EPG consumes contract team-consumed")))
+		})
+
+		By("Repairing drift on the APIC", func() {
+			apicClient.(*aci.ApicClientMocks).SetBridgeDomain(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant, "other-bd")
+			Expect(apicClient.ProvideContract(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant), "stray-contract")).Should(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			updated := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, lookupKey, updated)).Should(Succeed())
+			drift := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionDriftDetected)
+			Expect(drift).ShouldNot(BeNil())
+			Expect(drift.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(drift.Reason).Should(Equal(reasonDriftRepaired))
+			Expect(drift.Message).Should(ContainSubstring("provided contracts [stray-contract] are not desired"))
+			epg := apicClient.(*aci.ApicClientMocks).GetEpg(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+			Expect(epg.Bd).Should(Equal(cniConf.BridgeDomain))
+			provided, _ := apicClient.GetProvidedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(provided).Should(ConsistOf("provided-contract", "team-provided"))
+		})
+
+		By("Replacing the default contracts with the spec contracts", func() {
+			Expect(k8sClient.Get(ctx, lookupKey, conf)).Should(Succeed())
+			conf.Spec.ContractMode = v1alpha1.ContractModeReplace
+			Expect(k8sClient.Update(ctx, conf)).Should(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(consumed).Should(ConsistOf("team-consumed"))
+			provided, _ := apicClient.GetProvidedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(provided).Should(ConsistOf("team-provided"))
+			Eventually(recorder.Events).Should(Receive(ContainSubstring("Removed consumed contract consumed-contract")))
+		})
+
+		By("Removing contracts that are no longer in the spec", func() {
+			apicClient.(*aci.ApicClientMocks).ConsumeUnmanagedContract(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant, "hand-made")
+
+			Expect(k8sClient.Get(ctx, lookupKey, conf)).Should(Succeed())
+			conf.Spec.ContractMode = v1alpha1.ContractModeReplace
+			conf.Spec.ProvidedContracts = []string{}
+			Expect(k8sClient.Update(ctx, conf)).Should(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(consumed).Should(ConsistOf("team-consumed", "hand-made"))
+			provided, _ := apicClient.GetProvidedContracts(aci.EpgDn(namespace.Name+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(provided).Should(BeEmpty())
+			Eventually(recorder.Events).Should(Receive(ContainSubstring("Removed provided contract team-provided")))
+		})
+
+		By("Deleting the Epgconf resource", func() {
+			Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Epgconf Controller with a shared EPG", func() {
+	ctx := context.Background()
+
+	It("Should keep the EPG until the last namespace using it is gone", func() {
+		reconciler := &EpgconfReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			CniConfig:  cniConf,
+			Recorder:   record.NewFakeRecorder(1024),
+		}
+
+		keys := []types.NamespacedName{}
+		for _, team := range []string{"a", "b"} {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-shared-" + team}}
+			Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+			conf := &v1alpha1.Epgconf{
+				ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: namespace.Name},
+				Spec: v1alpha1.EpgconfSpec{
+					EpgName:           "shared-epg",
+					Shared:            true,
+					ConsumedContracts: []string{"team-" + team},
+				},
+			}
+			Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+			keys = append(keys, types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace})
+		}
+
+		By("Combining the contracts of all namespaces", func() {
+			for _, key := range append(keys, keys...) {
+				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+
+			consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn("shared-epg", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(consumed).Should(ConsistOf("consumed-contract", "team-a", "team-b"))
+
+			conf := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, keys[0], conf)).Should(Succeed())
+			Expect(conf.Status.SharedWith).Should(Equal([]string{"ns-shared-b"}))
+		})
+
+		By("Keeping the EPG when the first namespace is removed", func() {
+			conf := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, keys[0], conf)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[0]})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			exists, _ := apicClient.EpgExists("shared-epg", cniConf.ApplicationProfile, cniConf.Tenant)
+			Expect(exists).Should(BeTrue())
+			consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn("shared-epg", cniConf.ApplicationProfile, cniConf.Tenant))
+			Expect(consumed).Should(ConsistOf("consumed-contract", "team-b"))
+		})
+
+		By("Deleting the EPG with the last namespace", func() {
+			conf := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, keys[1], conf)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[1]})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			exists, _ := apicClient.EpgExists("shared-epg", cniConf.ApplicationProfile, cniConf.Tenant)
+			Expect(exists).Should(BeFalse())
+		})
+	})
+
+	It("Should refuse an EPG used by another namespace when it is not shared", func() {
+		reconciler := &EpgconfReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			CniConfig:  cniConf,
+			APIReader:  k8sClient,
+			Recorder:   record.NewFakeRecorder(1024),
+		}
+
+		keys := []types.NamespacedName{}
+		for _, team := range []string{"a", "b"} {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-unshared-" + team}}
+			Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+			conf := &v1alpha1.Epgconf{
+				ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: namespace.Name},
+				Spec: v1alpha1.EpgconfSpec{
+					EpgName: "unshared-epg",
+					Shared:  team == "a",
+				},
+			}
+			Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+			keys = append(keys, types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace})
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[0]})
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[1]})
+		Expect(err).Should(HaveOccurred())
+
+		conf := &v1alpha1.Epgconf{}
+		Expect(k8sClient.Get(ctx, keys[1], conf)).Should(Succeed())
+		Expect(conf.Status.EpgDn).Should(BeEmpty())
+		created := meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionEpgCreated)
+		Expect(created).ShouldNot(BeNil())
+		Expect(created.Reason).Should(Equal(reasonEpgNotShared))
+
+		By("Not counting the refused Epgconf as a reference", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: keys[0]})
+			Expect(err).ShouldNot(HaveOccurred())
+			conf := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, keys[0], conf)).Should(Succeed())
+			Expect(conf.Status.SharedWith).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Epgconf Controller with an existing EPG", func() {
+	ctx := context.Background()
+
+	reconcileConf := func(reconciler *EpgconfReconciler, namespace string, policy v1alpha1.AdoptionPolicy) (types.NamespacedName, error) {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).Should(Succeed())
+		apicClient.(*aci.ApicClientMocks).CreateUnownedEpg(namespace+"_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: namespace},
+			Spec:       v1alpha1.EpgconfSpec{AdoptionPolicy: policy},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		return key, err
+	}
+
+	deleteConf := func(reconciler *EpgconfReconciler, key types.NamespacedName) {
+		conf := &v1alpha1.Epgconf{}
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	It("Should use but not delete an EPG it did not create", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		key, err := reconcileConf(reconciler, "ns-adopt-create", v1alpha1.AdoptionPolicyCreate)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		conf := &v1alpha1.Epgconf{}
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(conf.Status.EpgOwned).Should(BeFalse())
+		epg := apicClient.(*aci.ApicClientMocks).GetEpg("ns-adopt-create_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(epg.Bd).Should(BeEmpty())
+		Expect(epg.Vmm).Should(BeEmpty())
+		Expect(meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionBridgeDomainBound)).Should(BeNil())
+		Expect(meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionVmmDomainBound)).Should(BeNil())
+
+		deleteConf(reconciler, key)
+		exists, _ := apicClient.EpgExists("ns-adopt-create_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeTrue())
+		consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn("ns-adopt-create_EPG", cniConf.ApplicationProfile, cniConf.Tenant))
+		Expect(consumed).Should(BeEmpty())
+	})
+
+	It("Should adopt an existing EPG", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		key, err := reconcileConf(reconciler, "ns-adopt-adopt", v1alpha1.AdoptionPolicyAdopt)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		owner, _ := apicClient.GetEpgTag("ns-adopt-adopt_EPG", cniConf.ApplicationProfile, cniConf.Tenant, aci.OwnerTagKey)
+		Expect(owner).Should(Equal(cniConf.ClusterID))
+
+		deleteConf(reconciler, key)
+		exists, _ := apicClient.EpgExists("ns-adopt-adopt_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeFalse())
+	})
+
+	It("Should fail on an existing EPG", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		key, err := reconcileConf(reconciler, "ns-adopt-fail", v1alpha1.AdoptionPolicyFail)
+		Expect(err).Should(HaveOccurred())
+
+		conf := &v1alpha1.Epgconf{}
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		condition := meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionEpgCreated)
+		Expect(condition).ShouldNot(BeNil())
+		Expect(condition.Reason).Should(Equal(reasonEpgNotOwned))
+	})
+
+	It("Should tag an EPG created by an earlier release", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-adopt-earlier"}})).Should(Succeed())
+		apicClient.(*aci.ApicClientMocks).CreateUntaggedEpg("ns-adopt-earlier_EPG", cniConf.ApplicationProfile, cniConf.Tenant, cniConf.VmmDomainType)
+
+		conf := &v1alpha1.Epgconf{ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-adopt-earlier"}}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(conf.Status.EpgOwned).Should(BeTrue())
+		owner, _ := apicClient.GetEpgTag("ns-adopt-earlier_EPG", cniConf.ApplicationProfile, cniConf.Tenant, aci.OwnerTagKey)
+		Expect(owner).Should(Equal(cniConf.ClusterID))
+		epg := apicClient.(*aci.ApicClientMocks).GetEpg("ns-adopt-earlier_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(epg.Bd).ShouldNot(BeEmpty())
+	})
+
+	It("Should not adopt an EPG owned by another cluster", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-adopt-other"}})).Should(Succeed())
+		apicClient.(*aci.ApicClientMocks).CreateUnownedEpg("ns-adopt-other_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(apicClient.SetEpgTag("ns-adopt-other_EPG", cniConf.ApplicationProfile, cniConf.Tenant, aci.OwnerTagKey, "other-cluster")).Should(Succeed())
+
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-adopt-other"},
+			Spec:       v1alpha1.EpgconfSpec{AdoptionPolicy: v1alpha1.AdoptionPolicyAdopt},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).Should(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(conf.Status.EpgOwned).Should(BeFalse())
+		condition := meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionEpgCreated)
+		Expect(condition).ShouldNot(BeNil())
+		Expect(condition.Reason).Should(Equal(reasonEpgNotOwned))
+		Expect(condition.Message).Should(ContainSubstring("other-cluster"))
+		owner, _ := apicClient.GetEpgTag("ns-adopt-other_EPG", cniConf.ApplicationProfile, cniConf.Tenant, aci.OwnerTagKey)
+		Expect(owner).Should(Equal("other-cluster"))
+	})
+})
+
+var _ = Describe("Epgconf Controller with a deletion policy", func() {
+	ctx := context.Background()
+
+	reconcileAndDelete := func(namespace string, policy v1alpha1.DeletionPolicy) string {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: namespace},
+			Spec:       v1alpha1.EpgconfSpec{DeletionPolicy: policy},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		return namespace + "_EPG"
+	}
+
+	It("Should keep the EPG and its contracts with Retain", func() {
+		epgName := reconcileAndDelete("ns-delete-retain", v1alpha1.DeletionPolicyRetain)
+
+		exists, _ := apicClient.EpgExists(epgName, cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeTrue())
+		orphaned, _ := apicClient.GetEpgTag(epgName, cniConf.ApplicationProfile, cniConf.Tenant, aci.OrphanedTagKey)
+		Expect(orphaned).Should(Equal("ns-delete-retain/epgconf"))
+		consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn(epgName, cniConf.ApplicationProfile, cniConf.Tenant))
+		Expect(consumed).Should(ConsistOf(cniConf.ConsumedContracts))
+
+		namespace := &corev1.Namespace{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "ns-delete-retain"}, namespace)).Should(Succeed())
+		Expect(namespace.GetAnnotations()).Should(HaveKey("opflex.cisco.com/endpoint-group"))
+	})
+
+	It("Should keep the EPG but remove the contracts with OrphanContractsOnly", func() {
+		epgName := reconcileAndDelete("ns-delete-orphan", v1alpha1.DeletionPolicyOrphanContractsOnly)
+
+		exists, _ := apicClient.EpgExists(epgName, cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeTrue())
+		orphaned, _ := apicClient.GetEpgTag(epgName, cniConf.ApplicationProfile, cniConf.Tenant, aci.OrphanedTagKey)
+		Expect(orphaned).Should(Equal("ns-delete-orphan/epgconf"))
+		consumed, _ := apicClient.GetConsumedContracts(aci.EpgDn(epgName, cniConf.ApplicationProfile, cniConf.Tenant))
+		Expect(consumed).Should(BeEmpty())
+		provided, _ := apicClient.GetProvidedContracts(aci.EpgDn(epgName, cniConf.ApplicationProfile, cniConf.Tenant))
+		Expect(provided).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Epgconf Controller with placement overrides", func() {
+	ctx := context.Background()
+
+	It("Should create the EPG in the tenant, application profile and bridge domain of the spec", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-override"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-override"},
+			Spec: v1alpha1.EpgconfSpec{
+				Tenant:             "other-tenant",
+				ApplicationProfile: "other-app",
+				BridgeDomain:       "isolated-bd",
+			},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(conf.Status.EpgDn).Should(Equal("uni/tn-other-tenant/ap-other-app/epg-ns-override_EPG"))
+		epg, _ := apicClient.ReadEpg("ns-override_EPG", "other-app", "other-tenant")
+		Expect(epg).ShouldNot(BeNil())
+		Expect(epg.BridgeDomain).Should(Equal("isolated-bd"))
+
+		namespace := &corev1.Namespace{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "ns-override"}, namespace)).Should(Succeed())
+		Expect(namespace.GetAnnotations()["opflex.cisco.com/endpoint-group"]).Should(ContainSubstring(`"tenant":"other-tenant"`))
+
+		Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		exists, _ := apicClient.EpgExists("ns-override_EPG", "other-app", "other-tenant")
+		Expect(exists).Should(BeFalse())
+	})
+})
+
+var _ = Describe("Epgconf Controller with allow-lists", func() {
+	ctx := context.Background()
+
+	It("Should not configure an EPG in a tenant that is not allowed", func() {
+		config := cniConf
+		config.AllowedTenants = []string{cniConf.Tenant}
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: config, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-not-allowed"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-not-allowed"},
+			Spec:       v1alpha1.EpgconfSpec{Tenant: "forbidden-tenant"},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).Should(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		created := meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionEpgCreated)
+		Expect(created).ShouldNot(BeNil())
+		Expect(created.Reason).Should(Equal(reasonNotAllowed))
+		exists, _ := apicClient.EpgExists("ns-not-allowed_EPG", cniConf.ApplicationProfile, "forbidden-tenant")
+		Expect(exists).Should(BeFalse())
+	})
+})
+
+var _ = Describe("Epgconf Controller creating missing parents", func() {
+	ctx := context.Background()
+
+	It("Should create the application profile and bridge domain", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-parents"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-parents"},
+			Spec: v1alpha1.EpgconfSpec{
+				Tenant:               "new-tenant",
+				ApplicationProfile:   "new-app",
+				BridgeDomain:         "new-bd",
+				CreateMissingParents: true,
+				Vrf:                  "new-vrf",
+				Subnets:              []string{"10.10.0.1/24"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		vrf, subnets, exists := apicClient.(*aci.ApicClientMocks).GetBridgeDomain("new-bd", "new-tenant")
+		Expect(exists).Should(BeTrue())
+		Expect(vrf).Should(Equal("new-vrf"))
+		Expect(subnets).Should(ConsistOf("10.10.0.1/24"))
+		created, _ := apicClient.EnsureApplicationProfile("new-app", "new-tenant")
+		Expect(created).Should(BeFalse())
+	})
+})
+
+var _ = Describe("Epgconf Controller with an ESG", func() {
+	ctx := context.Background()
+
+	It("Should put the EPG into the ESG and apply the contracts there", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-esg"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-esg"},
+			Spec: v1alpha1.EpgconfSpec{
+				Esg: &v1alpha1.EsgSpec{
+					Name:         "web-esg",
+					Create:       true,
+					TagSelectors: []v1alpha1.TagSelector{{Key: "tier", Value: "web"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		esgDn := aci.EsgDn("web-esg", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(conf.Status.EsgDn).Should(Equal(esgDn))
+		Expect(conf.Status.EsgOwned).Should(BeTrue())
+		Expect(meta.IsStatusConditionTrue(conf.Status.Conditions, v1alpha1.ConditionEsgBound)).Should(BeTrue())
+
+		esg, exists := apicClient.(*aci.ApicClientMocks).GetEsg("web-esg", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeTrue())
+		Expect(esg.EpgSelectors).Should(ConsistOf(conf.Status.EpgDn))
+		Expect(esg.TagSelectors).Should(ConsistOf(aci.TagSelector{Key: "tier", Value: "web"}))
+		consumed, _ := apicClient.GetConsumedContracts(esgDn)
+		Expect(consumed).Should(ConsistOf(cniConf.ConsumedContracts))
+		consumed, _ = apicClient.GetConsumedContracts(conf.Status.EpgDn)
+		Expect(consumed).Should(BeEmpty())
+
+		Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		_, exists = apicClient.(*aci.ApicClientMocks).GetEsg("web-esg", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeFalse())
+	})
+
+	It("Should fail on a missing ESG it may not create", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-esg-missing"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-esg-missing"},
+			Spec:       v1alpha1.EpgconfSpec{Esg: &v1alpha1.EsgSpec{Name: "missing-esg"}},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).Should(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(meta.IsStatusConditionFalse(conf.Status.Conditions, v1alpha1.ConditionEsgBound)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Epgconf Controller with missing contracts", func() {
+	ctx := context.Background()
+
+	It("Should report the missing contracts and bind them once they exist", func() {
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Recorder: record.NewFakeRecorder(1024)}
+		mock := apicClient.(*aci.ApicClientMocks)
+		mock.SetContractMissing("db-contract", true)
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-missing-contract"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-missing-contract"},
+			Spec:       v1alpha1.EpgconfSpec{ConsumedContracts: []string{"db-contract"}},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(contractMissingRequeueDelay))
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		missing := meta.FindStatusCondition(conf.Status.Conditions, v1alpha1.ConditionContractMissing)
+		Expect(missing).ShouldNot(BeNil())
+		Expect(missing.Status).Should(Equal(metav1.ConditionTrue))
+		Expect(missing.Message).Should(ContainSubstring("db-contract"))
+		Expect(meta.IsStatusConditionFalse(conf.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+		consumed, _ := apicClient.GetConsumedContracts(conf.Status.EpgDn)
+		Expect(consumed).Should(ConsistOf(cniConf.ConsumedContracts))
+
+		mock.SetContractMissing("db-contract", false)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(meta.IsStatusConditionFalse(conf.Status.Conditions, v1alpha1.ConditionContractMissing)).Should(BeTrue())
+		Expect(meta.IsStatusConditionTrue(conf.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+		consumed, _ = apicClient.GetConsumedContracts(conf.Status.EpgDn)
+		Expect(consumed).Should(ContainElement("db-contract"))
+	})
+})
+
+var _ = Describe("Epgconf Controller while the APIC is unreachable", func() {
+	ctx := context.Background()
+
+	It("Should report the APIC as unavailable and reconcile once it is back", func() {
+		connectivity := &ApicConnectivity{}
+		changes := connectivity.Changes()
+		reconciler := &EpgconfReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ApicClient: apicClient, CniConfig: cniConf, Connectivity: connectivity, Recorder: record.NewFakeRecorder(1024)}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-apic-down"}})).Should(Succeed())
+		conf := &v1alpha1.Epgconf{ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-apic-down"}}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+		key := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+
+		connectivity.Observe(ctx, fmt.Errorf("ListSystem: %w: connection refused", aci.ErrTransient))
+		Expect(changes).Should(Receive())
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(meta.IsStatusConditionTrue(conf.Status.Conditions, v1alpha1.ConditionApicUnavailable)).Should(BeTrue())
+		Expect(meta.IsStatusConditionFalse(conf.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+		exists, _ := apicClient.EpgExists("ns-apic-down_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeFalse())
+
+		connectivity.Observe(ctx, nil)
+		Expect(changes).Should(Receive())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, key, conf)).Should(Succeed())
+		Expect(meta.IsStatusConditionFalse(conf.Status.Conditions, v1alpha1.ConditionApicUnavailable)).Should(BeTrue())
+		Expect(meta.IsStatusConditionTrue(conf.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+		exists, _ = apicClient.EpgExists("ns-apic-down_EPG", cniConf.ApplicationProfile, cniConf.Tenant)
+		Expect(exists).Should(BeTrue())
+	})
+})
@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/samber/lo"
+)
+
+// Reasons used on the EpgOperatorConfig conditions.
+const (
+	reasonConfigValid   = "Valid"
+	reasonConfigInvalid = "Invalid"
+	reasonConfigUnused  = "NotUsed"
+)
+
+// EpgOperatorConfigReconciler validates the EpgOperatorConfig together with
+// the ConfigMaps of the ACI CNI, applies the config to the operator and
+// reports the result in its status.
+type EpgOperatorConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Name is the name of the EpgOperatorConfig used by the operator,
+	// "default" when empty.
+	Name string
+	// EpgNameTemplate is the naming template used when the
+	// EpgOperatorConfig has none.
+	EpgNameTemplate string
+	// SetConfig validates the config and applies it to the operator, the
+	// config is only validated when it is nil.
+	SetConfig func(ctx context.Context, config CniConfig) error
+}
+
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=epgoperatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=epgoperatorconfigs/status,verbs=get;update;patch
+
+// Reconcile applies the config the operator builds from the
+// EpgOperatorConfig and the ConfigMaps. EpgOperatorConfigs with another name
+// are not used. A config that can not be loaded or is invalid is retried
+// with backoff, it may depend on a ConfigMap that is not there yet.
+func (r *EpgOperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	operatorConfig := &epgv1alpha1.EpgOperatorConfig{}
+	err := r.Get(ctx, req.NamespacedName, operatorConfig)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if req.Name != r.configName() {
+			return ctrl.Result{}, nil
+		}
+		// Without EpgOperatorConfig the config is built from the ConfigMaps
+		// alone, there is no status to report an error in.
+		l.Info("EpgOperatorConfig resource not found, using the ConfigMaps of the ACI CNI")
+		_, err = r.applyConfig(ctx)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("error occurred while applying the config: %w", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	operatorConfig.Status.ObservedGeneration = operatorConfig.GetGeneration()
+	if operatorConfig.GetName() != r.configName() {
+		r.setReady(operatorConfig, metav1.ConditionFalse, reasonConfigUnused, fmt.Sprintf("The operator uses EpgOperatorConfig %s", r.configName()))
+		return ctrl.Result{}, r.Status().Update(ctx, operatorConfig)
+	}
+
+	found, err := r.applyConfig(ctx)
+	operatorConfig.Status.AciContainersConfig = found
+	if err != nil {
+		r.setReady(operatorConfig, metav1.ConditionFalse, reasonConfigInvalid, err.Error())
+	} else {
+		r.setReady(operatorConfig, metav1.ConditionTrue, reasonConfigValid, "The operator config is complete")
+	}
+
+	updateErr := r.Status().Update(ctx, operatorConfig)
+	if updateErr != nil {
+		return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", updateErr)
+	}
+	return ctrl.Result{}, err
+}
+
+// applyConfig loads the config and applies it with SetConfig, it reports if
+// the aci-containers-config ConfigMap was found.
+func (r *EpgOperatorConfigReconciler) applyConfig(ctx context.Context) (bool, error) {
+	cniConfig, _, found, err := LoadCniConfig(ctx, r.Client, r.configName())
+	if err != nil {
+		return found, err
+	}
+	if cniConfig.EpgNameTemplate == "" {
+		cniConfig.EpgNameTemplate = r.EpgNameTemplate
+	}
+	if r.SetConfig == nil {
+		return found, ValidateCniConfig(cniConfig)
+	}
+	return found, r.SetConfig(ctx, cniConfig)
+}
+
+func (r *EpgOperatorConfigReconciler) configName() string {
+	return lo.Ternary(r.Name != "", r.Name, DefaultOperatorConfigName)
+}
+
+func (r *EpgOperatorConfigReconciler) setReady(operatorConfig *epgv1alpha1.EpgOperatorConfig, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&operatorConfig.Status.Conditions, metav1.Condition{
+		Type:               epgv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: operatorConfig.GetGeneration(),
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager. Changes to the
+// ConfigMaps of the ACI CNI apply the config again.
+func (r *EpgOperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&epgv1alpha1.EpgOperatorConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: r.configName()}}}
+			}),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isCniConfigMap))).
+		Complete(r)
+}
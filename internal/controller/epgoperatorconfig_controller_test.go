@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+var _ = Describe("EpgOperatorConfig Controller", func() {
+	ctx := context.Background()
+
+	It("Should report if the operator config is valid", func() {
+		operatorConfig := &v1alpha1.EpgOperatorConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "operator-config"},
+			Spec: v1alpha1.EpgOperatorConfigSpec{
+				ApicHosts:          []string{"10.0.0.1"},
+				Tenant:             "optest",
+				ApplicationProfile: "optest-app",
+				BridgeDomain:       "optest-pod-bd",
+				VmmDomain:          "optest",
+				VmmDomainType:      "Kubernetes",
+			},
+		}
+		Expect(k8sClient.Create(ctx, operatorConfig)).Should(Succeed())
+		unused := &v1alpha1.EpgOperatorConfig{ObjectMeta: metav1.ObjectMeta{Name: "unused-config"}}
+		Expect(k8sClient.Create(ctx, unused)).Should(Succeed())
+
+		var applied *CniConfig
+		reconciler := &EpgOperatorConfigReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+			Name:   operatorConfig.Name,
+			SetConfig: func(_ context.Context, config CniConfig) error {
+				applied = &config
+				return nil
+			},
+		}
+
+		By("Applying the config in use and setting Ready", func() {
+			key := types.NamespacedName{Name: operatorConfig.Name}
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, operatorConfig)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(operatorConfig.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+			Expect(applied).ShouldNot(BeNil())
+			Expect(applied.Tenant).Should(Equal("optest"))
+		})
+
+		By("Retrying a config that can not be applied", func() {
+			failing := *reconciler
+			failing.SetConfig = func(context.Context, CniConfig) error {
+				return errors.New("VMM domain optest is not in the allowed VMM domains")
+			}
+			key := types.NamespacedName{Name: operatorConfig.Name}
+			_, err := failing.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).Should(HaveOccurred())
+			Expect(errors.Is(err, reconcile.TerminalError(nil))).Should(BeFalse())
+
+			Expect(k8sClient.Get(ctx, key, operatorConfig)).Should(Succeed())
+			condition := meta.FindStatusCondition(operatorConfig.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(condition).ShouldNot(BeNil())
+			Expect(condition.Reason).Should(Equal(reasonConfigInvalid))
+			Expect(condition.Message).Should(ContainSubstring("allowed VMM domains"))
+		})
+
+		By("Reporting a config with another name as not used", func() {
+			key := types.NamespacedName{Name: unused.Name}
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, unused)).Should(Succeed())
+			condition := meta.FindStatusCondition(unused.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(condition).ShouldNot(BeNil())
+			Expect(condition.Reason).Should(Equal(reasonConfigUnused))
+		})
+	})
+})
@@ -0,0 +1,261 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// Reasons used on the EpgPolicy conditions.
+const (
+	reasonInvalidSelector   = "InvalidSelector"
+	reasonNamespaceConflict = "NamespaceConflict"
+)
+
+// EpgPolicyReconciler reconciles an EpgPolicy object
+type EpgPolicyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=epgpolicies,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=epgpolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=epg.custom.aci,resources=epgpolicies/finalizers,verbs=update
+
+// Reconcile creates an Epgconf in every namespace selected by the EpgPolicy,
+// keeps their spec in line with the template and deletes the Epgconfs of
+// namespaces that are no longer selected. Epgconfs of a deleted EpgPolicy are
+// garbage collected through their owner reference.
+func (r *EpgPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	policy := &epgv1alpha1.EpgPolicy{}
+	err := r.Get(ctx, req.NamespacedName, policy)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("EpgPolicy resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if policy.GetDeletionTimestamp() != nil {
+		return ctrl.Result{}, nil
+	}
+
+	policy.Status.ObservedGeneration = policy.GetGeneration()
+	selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+	if err != nil {
+		r.setPolicyReady(policy, metav1.ConditionFalse, reasonInvalidSelector, err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, policy)
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	err = r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	selected := map[string]bool{}
+	conflicts := []string{}
+	for _, namespace := range namespaces.Items {
+		if namespace.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		conflict, err := r.ensureEpgconf(ctx, l, policy, namespace.Name)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if conflict {
+			conflicts = append(conflicts, namespace.Name)
+			continue
+		}
+		selected[namespace.Name] = true
+	}
+
+	err = r.deleteUnselectedEpgconfs(ctx, l, policy, selected)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	policy.Status.Namespaces = make([]string, 0, len(selected))
+	for namespace := range selected {
+		policy.Status.Namespaces = append(policy.Status.Namespaces, namespace)
+	}
+	sort.Strings(policy.Status.Namespaces)
+	policy.Status.Conflicts = conflicts
+	if len(conflicts) > 0 {
+		r.setPolicyReady(policy, metav1.ConditionFalse, reasonNamespaceConflict,
+			fmt.Sprintf("Namespaces %v already have an Epgconf not created by this policy", conflicts))
+	} else {
+		r.setPolicyReady(policy, metav1.ConditionTrue, reasonReconciled,
+			fmt.Sprintf("%d namespaces have an Epgconf", len(selected)))
+	}
+
+	err = r.Status().Update(ctx, policy)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error occurred while setting the status: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// ensureEpgconf creates or updates the Epgconf of the policy in the namespace.
+// It reports a conflict if the namespace already has an Epgconf that was not
+// created by the policy.
+func (r *EpgPolicyReconciler) ensureEpgconf(ctx context.Context, l logr.Logger, policy *epgv1alpha1.EpgPolicy, namespace string) (bool, error) {
+	confs := &epgv1alpha1.EpgconfList{}
+	err := r.List(ctx, confs, client.InNamespace(namespace))
+	if err != nil {
+		return false, err
+	}
+
+	desiredSpec := policy.Spec.Template.DeepCopy()
+	// Set the defaults of the Epgconf CRD, the spec would never match otherwise.
+	if desiredSpec.ContractMode == "" {
+		desiredSpec.ContractMode = epgv1alpha1.ContractModeMerge
+	}
+	if desiredSpec.AdoptionPolicy == "" {
+		desiredSpec.AdoptionPolicy = epgv1alpha1.AdoptionPolicyCreate
+	}
+
+	var existing *epgv1alpha1.Epgconf
+	for i, conf := range confs.Items {
+		if conf.GetLabels()[epgv1alpha1.PolicyLabel] == policy.Name {
+			existing = &confs.Items[i]
+			continue
+		}
+		return true, nil
+	}
+
+	if existing == nil {
+		conf := &epgv1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      policy.Name,
+				Namespace: namespace,
+				Labels:    map[string]string{epgv1alpha1.PolicyLabel: policy.Name},
+			},
+			Spec: *desiredSpec,
+		}
+		err = controllerutil.SetControllerReference(policy, conf, r.Scheme)
+		if err != nil {
+			return false, err
+		}
+		l.Info(fmt.Sprintf("Creating Epgconf in namespace %s", namespace))
+		err = r.Create(ctx, conf)
+		if err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(policy, corev1.EventTypeNormal, "EpgconfCreated", "Created Epgconf in namespace %s", namespace)
+		return false, nil
+	}
+
+	if !equality.Semantic.DeepEqual(existing.Spec, *desiredSpec) {
+		existing.Spec = *desiredSpec
+		l.Info(fmt.Sprintf("Updating Epgconf in namespace %s", namespace))
+		err = r.Update(ctx, existing)
+		if err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(policy, corev1.EventTypeNormal, "EpgconfUpdated", "Updated Epgconf in namespace %s", namespace)
+	}
+	return false, nil
+}
+
+// deleteUnselectedEpgconfs deletes the Epgconfs of the policy in namespaces
+// that are no longer selected.
+func (r *EpgPolicyReconciler) deleteUnselectedEpgconfs(ctx context.Context, l logr.Logger, policy *epgv1alpha1.EpgPolicy, selected map[string]bool) error {
+	confs := &epgv1alpha1.EpgconfList{}
+	err := r.List(ctx, confs, client.MatchingLabels{epgv1alpha1.PolicyLabel: policy.Name})
+	if err != nil {
+		return err
+	}
+
+	for i, conf := range confs.Items {
+		if selected[conf.Namespace] || conf.GetDeletionTimestamp() != nil {
+			continue
+		}
+		l.Info(fmt.Sprintf("Deleting Epgconf in namespace %s, it is no longer selected", conf.Namespace))
+		err = r.Delete(ctx, &confs.Items[i])
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.Recorder.Eventf(policy, corev1.EventTypeNormal, "EpgconfDeleted", "Deleted Epgconf in namespace %s", conf.Namespace)
+	}
+	return nil
+}
+
+func (r *EpgPolicyReconciler) setPolicyReady(policy *epgv1alpha1.EpgPolicy, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&policy.Status.Conditions, metav1.Condition{
+		Type:               epgv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: policy.GetGeneration(),
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EpgPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&epgv1alpha1.EpgPolicy{}).
+		Watches(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueAllPolicies),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&epgv1alpha1.Epgconf{},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueAllPolicies),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// enqueueAllPolicies enqueues every EpgPolicy, a namespace label change or an
+// Epgconf created or deleted by hand can change what any policy selects.
+func (r *EpgPolicyReconciler) enqueueAllPolicies(ctx context.Context, _ client.Object) []reconcile.Request {
+	policies := &epgv1alpha1.EpgPolicyList{}
+	err := r.List(ctx, policies)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "error occurred while listing EpgPolicy resources")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(policies.Items))
+	for i, policy := range policies.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: policy.Name}}
+	}
+	return requests
+}
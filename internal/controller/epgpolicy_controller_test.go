@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+var _ = Describe("EpgPolicy Controller", func() {
+	ctx := context.Background()
+
+	It("Should manage Epgconfs in the selected namespaces", func() {
+		selected := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "ns-policy-a", Labels: map[string]string{"team": "a"}},
+		}
+		Expect(k8sClient.Create(ctx, selected)).Should(Succeed())
+		conflicting := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "ns-policy-b", Labels: map[string]string{"team": "a"}},
+		}
+		Expect(k8sClient.Create(ctx, conflicting)).Should(Succeed())
+		Expect(k8sClient.Create(ctx, &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "by-hand", Namespace: conflicting.Name},
+		})).Should(Succeed())
+
+		policy := &v1alpha1.EpgPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+			Spec: v1alpha1.EpgPolicySpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				Template: v1alpha1.EpgconfSpec{
+					ConsumedContracts: []string{"team-a-shared"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, policy)).Should(Succeed())
+
+		reconciler := &EpgPolicyReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(1024),
+		}
+		policyKey := types.NamespacedName{Name: policy.Name}
+		confKey := types.NamespacedName{Name: policy.Name, Namespace: selected.Name}
+
+		By("Creating an Epgconf in the selected namespace", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: policyKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			conf := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, confKey, conf)).Should(Succeed())
+			Expect(conf.Spec.ConsumedContracts).Should(Equal([]string{"team-a-shared"}))
+			Expect(conf.GetLabels()[v1alpha1.PolicyLabel]).Should(Equal(policy.Name))
+			Expect(conf.GetOwnerReferences()).Should(HaveLen(1))
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: policy.Name, Namespace: conflicting.Name}, &v1alpha1.Epgconf{})
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+
+			Expect(k8sClient.Get(ctx, policyKey, policy)).Should(Succeed())
+			Expect(policy.Status.Namespaces).Should(Equal([]string{selected.Name}))
+			Expect(policy.Status.Conflicts).Should(Equal([]string{conflicting.Name}))
+			Expect(meta.IsStatusConditionFalse(policy.Status.Conditions, v1alpha1.ConditionReady)).Should(BeTrue())
+		})
+
+		By("Updating the Epgconf when the template changes", func() {
+			policy.Spec.Template.ConsumedContracts = []string{"team-a-db"}
+			Expect(k8sClient.Update(ctx, policy)).Should(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: policyKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			conf := &v1alpha1.Epgconf{}
+			Expect(k8sClient.Get(ctx, confKey, conf)).Should(Succeed())
+			Expect(conf.Spec.ConsumedContracts).Should(Equal([]string{"team-a-db"}))
+		})
+
+		By("Deleting the Epgconf when the namespace is no longer selected", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: selected.Name}, selected)).Should(Succeed())
+			selected.Labels = map[string]string{"team": "b"}
+			Expect(k8sClient.Update(ctx, selected)).Should(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: policyKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, confKey, &v1alpha1.Epgconf{})
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	It("Should reject names that do not fit in the policy label", func() {
+		policy := &v1alpha1.EpgPolicy{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 64)}}
+		err := k8sClient.Create(ctx, policy)
+		Expect(errors.IsInvalid(err)).Should(BeTrue())
+		Expect(err.Error()).Should(ContainSubstring("no more than 63 characters"))
+
+		policy = &v1alpha1.EpgPolicy{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 63)}}
+		Expect(k8sClient.Create(ctx, policy)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, policy)).Should(Succeed())
+	})
+})
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+)
+
+const (
+	// transientRequeueDelay is used when the APIC could not be reached, the
+	// client has already retried with backoff.
+	transientRequeueDelay = 30 * time.Second
+	// throttledRequeueDelay is used when the APIC rate limits the operator.
+	throttledRequeueDelay = time.Minute
+	// authFailedRequeueDelay is used when the APIC rejects the credentials,
+	// which usually needs an administrator to fix.
+	authFailedRequeueDelay = 5 * time.Minute
+	// contractMissingRequeueDelay is used while contracts are missing, a
+	// contract created on the APIC does not trigger a reconcile.
+	contractMissingRequeueDelay = time.Minute
+)
+
+// errContractMissing is returned when contracts of an Epgconf do not exist
+// on the APIC.
+var errContractMissing = errors.New("missing contracts")
+
+// resultForError decides how a failed reconcile is retried. Transient APIC
+// errors are requeued after a delay, errors the APIC reports for the
+// configuration are terminal until the Epgconf or the operator config change.
+// Other errors, including APIC errors of an unknown class, are returned to be
+// retried with the rate limiter.
+func resultForError(l logr.Logger, err error) (ctrl.Result, error) {
+	switch {
+	case errors.Is(err, aci.ErrTransient):
+		l.Info("APIC is unavailable, requeueing", "after", transientRequeueDelay.String(), "error", err.Error())
+		return ctrl.Result{RequeueAfter: transientRequeueDelay}, nil
+	case errors.Is(err, aci.ErrThrottled):
+		l.Info("APIC throttled the operator, requeueing", "after", throttledRequeueDelay.String(), "error", err.Error())
+		return ctrl.Result{RequeueAfter: throttledRequeueDelay}, nil
+	case errors.Is(err, aci.ErrAuthFailed):
+		l.Error(err, "APIC rejected the credentials, requeueing", "after", authFailedRequeueDelay.String())
+		return ctrl.Result{RequeueAfter: authFailedRequeueDelay}, nil
+	case errors.Is(err, errContractMissing):
+		l.Info("Contracts are missing on the APIC, requeueing", "after", contractMissingRequeueDelay.String(), "error", err.Error())
+		return ctrl.Result{RequeueAfter: contractMissingRequeueDelay}, nil
+	case errors.Is(err, aci.ErrInvalidConfig), errors.Is(err, aci.ErrNotFound):
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	default:
+		return ctrl.Result{}, err
+	}
+}
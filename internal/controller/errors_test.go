@@ -0,0 +1,71 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+)
+
+var _ = Describe("APIC error handling", func() {
+	apicError := func(class error) error {
+		return &aci.ApicError{Operation: "CreateEpg", Class: class, Err: errors.New("apic error")}
+	}
+
+	It("Should requeue transient errors after a delay", func() {
+		result, err := resultForError(logf.Log, apicError(aci.ErrTransient))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(transientRequeueDelay))
+
+		result, err = resultForError(logf.Log, apicError(aci.ErrThrottled))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(throttledRequeueDelay))
+
+		result, err = resultForError(logf.Log, apicError(aci.ErrAuthFailed))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(authFailedRequeueDelay))
+	})
+
+	It("Should requeue missing contracts after a delay", func() {
+		result, err := resultForError(logf.Log, fmt.Errorf("%w: db not found in tenant optest or common", errContractMissing))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(contractMissingRequeueDelay))
+	})
+
+	It("Should not retry configuration errors", func() {
+		_, err := resultForError(logf.Log, apicError(aci.ErrInvalidConfig))
+		Expect(errors.Is(err, reconcile.TerminalError(nil))).Should(BeTrue())
+		Expect(errors.Is(err, aci.ErrInvalidConfig)).Should(BeTrue())
+	})
+
+	It("Should return other errors to the rate limiter", func() {
+		_, err := resultForError(logf.Log, errors.New("conflict"))
+		Expect(err).Should(HaveOccurred())
+		Expect(errors.Is(err, reconcile.TerminalError(nil))).Should(BeFalse())
+
+		_, err = resultForError(logf.Log, apicError(aci.ErrUnknown))
+		Expect(err).Should(HaveOccurred())
+		Expect(errors.Is(err, reconcile.TerminalError(nil))).Should(BeFalse())
+	})
+})
@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+var (
+	managedEpgs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "epg_operator_managed_epgs",
+			Help: "Number of distinct EPGs on the APIC owned by the operator.",
+		},
+	)
+	epgconfsByState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "epg_operator_epgconfs",
+			Help: "Number of Epgconf resources per state.",
+		},
+		[]string{"state"},
+	)
+	apicReachable = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "epg_operator_apic_reachable",
+			Help: "Set to 1 while the APIC can be reached with the credentials, 0 otherwise.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(managedEpgs, epgconfsByState, apicReachable)
+}
+
+// epgconfMetrics remembers the state and the EPG of every reconciled Epgconf,
+// so that the gauges are updated from the reconciled object without listing
+// all Epgconfs.
+type epgconfMetrics struct {
+	lock   sync.Mutex
+	states map[types.NamespacedName]string
+	epgs   map[types.NamespacedName]string
+	// epgRefs counts the Epgconfs owning each EPG, a shared EPG is counted once.
+	epgRefs map[string]int
+}
+
+var epgconfStates = &epgconfMetrics{
+	states:  map[types.NamespacedName]string{},
+	epgs:    map[types.NamespacedName]string{},
+	epgRefs: map[string]int{},
+}
+
+// record updates the gauges for the Epgconf, a nil Epgconf has been deleted.
+func (m *epgconfMetrics) record(key types.NamespacedName, conf *epgv1alpha1.Epgconf) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if state, found := m.states[key]; found {
+		epgconfsByState.WithLabelValues(state).Dec()
+		delete(m.states, key)
+	}
+	if epg, found := m.epgs[key]; found {
+		m.epgRefs[epg]--
+		if m.epgRefs[epg] == 0 {
+			delete(m.epgRefs, epg)
+		}
+		delete(m.epgs, key)
+	}
+
+	if conf != nil {
+		state := conf.Status.State
+		if state == "" {
+			state = "Pending"
+		}
+		m.states[key] = state
+		epgconfsByState.WithLabelValues(state).Inc()
+		// Existing EPGs the operator uses without owning them are not counted.
+		if conf.GetDeletionTimestamp() == nil && conf.Status.EpgOwned && meta.IsStatusConditionTrue(conf.Status.Conditions, epgv1alpha1.ConditionEpgCreated) {
+			m.epgs[key] = conf.Status.EpgDn
+			m.epgRefs[conf.Status.EpgDn]++
+		}
+	}
+	managedEpgs.Set(float64(len(m.epgRefs)))
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+var _ = Describe("Epgconf metrics", func() {
+	readyConf := func(epgDn string) *v1alpha1.Epgconf {
+		conf := &v1alpha1.Epgconf{Status: v1alpha1.EpgconfStatus{State: "Ready", EpgDn: epgDn, EpgOwned: true}}
+		setCondition(conf, v1alpha1.ConditionEpgCreated, metav1.ConditionTrue, reasonSucceeded, "EPG exists")
+		return conf
+	}
+
+	It("Should update the gauges from the reconciled Epgconfs", func() {
+		ready := testutil.ToFloat64(epgconfsByState.WithLabelValues("Ready"))
+		failed := testutil.ToFloat64(epgconfsByState.WithLabelValues("Failed"))
+		epgs := testutil.ToFloat64(managedEpgs)
+		a := types.NamespacedName{Namespace: "ns-metrics-a", Name: "epgconf"}
+		b := types.NamespacedName{Namespace: "ns-metrics-b", Name: "epgconf"}
+		c := types.NamespacedName{Namespace: "ns-metrics-c", Name: "epgconf"}
+
+		epgconfStates.record(a, readyConf("uni/tn-optest/ap-app/epg-metrics"))
+		epgconfStates.record(b, readyConf("uni/tn-optest/ap-app/epg-metrics"))
+		Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Ready"))).Should(Equal(ready + 2))
+		Expect(testutil.ToFloat64(managedEpgs)).Should(Equal(epgs + 1))
+
+		By("Moving an Epgconf to its new state", func() {
+			epgconfStates.record(a, &v1alpha1.Epgconf{Status: v1alpha1.EpgconfStatus{State: "Failed"}})
+			Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Ready"))).Should(Equal(ready + 1))
+			Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Failed"))).Should(Equal(failed + 1))
+			Expect(testutil.ToFloat64(managedEpgs)).Should(Equal(epgs + 1))
+		})
+
+		By("Not counting EPGs the operator does not own", func() {
+			unowned := readyConf("uni/tn-optest/ap-app/epg-metrics-unowned")
+			unowned.Status.EpgOwned = false
+			epgconfStates.record(c, unowned)
+			Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Ready"))).Should(Equal(ready + 2))
+			Expect(testutil.ToFloat64(managedEpgs)).Should(Equal(epgs + 1))
+		})
+
+		By("Forgetting deleted Epgconfs", func() {
+			epgconfStates.record(a, nil)
+			epgconfStates.record(b, nil)
+			epgconfStates.record(c, nil)
+			Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Ready"))).Should(Equal(ready))
+			Expect(testutil.ToFloat64(epgconfsByState.WithLabelValues("Failed"))).Should(Equal(failed))
+			Expect(testutil.ToFloat64(managedEpgs)).Should(Equal(epgs))
+		})
+	})
+})
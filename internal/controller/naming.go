@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+	"github.com/samber/lo"
+)
+
+// DefaultEpgNameTemplate names the EPG after the namespace, as the operator
+// has always done.
+const DefaultEpgNameTemplate = "{{ .Namespace }}_EPG"
+
+// EpgNameData is the data available to the EPG name template.
+type EpgNameData struct {
+	// Namespace is the namespace of the Epgconf.
+	Namespace string
+	// Name is the name of the Epgconf.
+	Name string
+	// Cluster is the name of the cluster, the aci-prefix of the ACI CNI by default.
+	Cluster string
+}
+
+// RenderEpgName executes the EPG name template and validates the result
+// against the APIC naming rules.
+func RenderEpgName(nameTemplate string, data EpgNameData) (string, error) {
+	if nameTemplate == "" {
+		nameTemplate = DefaultEpgNameTemplate
+	}
+	tmpl, err := template.New("epg-name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid EPG name template: %w", err)
+	}
+
+	var name bytes.Buffer
+	err = tmpl.Execute(&name, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid EPG name template: %w", err)
+	}
+
+	err = aci.ValidateName(name.String())
+	if err != nil {
+		return "", fmt.Errorf("invalid EPG name: %w", err)
+	}
+	return name.String(), nil
+}
+
+// EpgName returns the name of the EPG for the Epgconf, the name in the spec if
+// set, otherwise the name rendered from the template in the config.
+func EpgName(conf *epgv1alpha1.Epgconf, config CniConfig) (string, error) {
+	if conf.Spec.EpgName != "" {
+		err := aci.ValidateName(conf.Spec.EpgName)
+		if err != nil {
+			return "", fmt.Errorf("invalid EPG name: %w", err)
+		}
+		return conf.Spec.EpgName, nil
+	}
+	return RenderEpgName(config.EpgNameTemplate, EpgNameData{
+		Namespace: conf.GetNamespace(),
+		Name:      conf.GetName(),
+		Cluster:   config.ClusterName,
+	})
+}
+
+// Epg identifies the EPG of an Epgconf on the APIC.
+type Epg struct {
+	Name               string
+	ApplicationProfile string
+	Tenant             string
+	BridgeDomain       string
+}
+
+// Dn returns the distinguished name of the EPG.
+func (e Epg) Dn() string {
+	return aci.EpgDn(e.Name, e.ApplicationProfile, e.Tenant)
+}
+
+// ResolveEpg returns the EPG of the Epgconf. The tenant, application profile
+// and bridge domain in the spec override the ones of the ACI CNI.
+func ResolveEpg(conf *epgv1alpha1.Epgconf, config CniConfig) (Epg, error) {
+	name, err := EpgName(conf, config)
+	if err != nil {
+		return Epg{}, err
+	}
+	return Epg{
+		Name:               name,
+		ApplicationProfile: lo.Ternary(conf.Spec.ApplicationProfile != "", conf.Spec.ApplicationProfile, config.ApplicationProfile),
+		Tenant:             lo.Ternary(conf.Spec.Tenant != "", conf.Spec.Tenant, config.Tenant),
+		BridgeDomain:       lo.Ternary(conf.Spec.BridgeDomain != "", conf.Spec.BridgeDomain, config.BridgeDomain),
+	}, nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/4ndersson/epg-config-operator/api/v1alpha1"
+)
+
+var _ = Describe("EPG naming", func() {
+	ctx := context.Background()
+
+	It("Should render the EPG name template", func() {
+		name, err := RenderEpgName("", EpgNameData{Namespace: "ns-1"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(name).Should(Equal("ns-1_EPG"))
+
+		name, err = RenderEpgName("{{ .Cluster }}-{{ .Namespace }}", EpgNameData{Namespace: "ns-1", Cluster: "ocp"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(name).Should(Equal("ocp-ns-1"))
+	})
+
+	It("Should reject names the APIC does not accept", func() {
+		_, err := RenderEpgName("{{ .Namespace }}/{{ .Name }}", EpgNameData{Namespace: "ns-1", Name: "conf"})
+		Expect(err).Should(HaveOccurred())
+
+		_, err = RenderEpgName("{{ .Namespace }}", EpgNameData{Namespace: strings.Repeat("a", 65)})
+		Expect(err).Should(HaveOccurred())
+
+		_, err = RenderEpgName("{{ .Unknown }}", EpgNameData{Namespace: "ns-1"})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should use the template and the spec override for the EPG", func() {
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "ns-naming",
+			},
+		}
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+
+		conf := &v1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "epg-naming-test",
+				Namespace: namespace.Name,
+			},
+		}
+		Expect(k8sClient.Create(ctx, conf)).Should(Succeed())
+
+		templateConf := cniConf
+		templateConf.EpgNameTemplate = "{{ .Cluster }}-{{ .Namespace }}"
+		templateConf.ClusterName = "ocp"
+		reconciler := &EpgconfReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			ApicClient: apicClient,
+			CniConfig:  templateConf,
+			Recorder:   record.NewFakeRecorder(1024),
+		}
+		lookupKey := types.NamespacedName{Name: conf.Name, Namespace: conf.Namespace}
+
+		By("Naming the EPG from the template", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			exists, _ := apicClient.EpgExists("ocp-ns-naming", cniConf.ApplicationProfile, cniConf.Tenant)
+			Expect(exists).Should(BeTrue())
+
+			updated := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace.Name}, updated)).Should(Succeed())
+			Expect(updated.Annotations["opflex.cisco.com/endpoint-group"]).Should(ContainSubstring(`"name":"ocp-ns-naming"`))
+		})
+
+		By("Deleting the EPG by the name it was created with", func() {
+			Expect(k8sClient.Get(ctx, lookupKey, conf)).Should(Succeed())
+			Expect(conf.Status.EpgName).Should(Equal("ocp-ns-naming"))
+
+			Expect(k8sClient.Delete(ctx, conf)).Should(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			exists, _ := apicClient.EpgExists("ocp-ns-naming", cniConf.ApplicationProfile, cniConf.Tenant)
+			Expect(exists).Should(BeFalse())
+		})
+
+		By("Using the EPG name from the spec", func() {
+			override := &v1alpha1.Epgconf{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "epg-naming-override",
+					Namespace: namespace.Name,
+				},
+				Spec: v1alpha1.EpgconfSpec{
+					EpgName: "custom-epg",
+				},
+			}
+			Expect(k8sClient.Create(ctx, override)).Should(Succeed())
+
+			overrideKey := types.NamespacedName{Name: override.Name, Namespace: override.Namespace}
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: overrideKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			exists, _ := apicClient.EpgExists("custom-epg", cniConf.ApplicationProfile, cniConf.Tenant)
+			Expect(exists).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, override)).Should(Succeed())
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: overrideKey})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
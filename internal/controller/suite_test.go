@@ -107,6 +107,7 @@ var _ = BeforeSuite(func() {
 		ApplicationProfile: "optest",
 		ProvidedContracts:  []string{"provided-contract"},
 		ConsumedContracts:  []string{"consumed-contract"},
+		ClusterID:          "test-cluster",
 	}
 
 	err = (&EpgconfReconciler{
@@ -114,6 +115,7 @@ var _ = BeforeSuite(func() {
 		Scheme:     k8sManager.GetScheme(),
 		ApicClient: apicClient,
 		CniConfig:  cniConf,
+		Recorder:   k8sManager.GetEventRecorderFor("epgconf-controller"),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
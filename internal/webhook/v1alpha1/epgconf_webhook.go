@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/4ndersson/epg-config-operator/internal/controller"
+	"github.com/4ndersson/epg-config-operator/pkg/aci"
+)
+
+var epgconflog = logf.Log.WithName("epgconf-resource")
+
+// SetupEpgconfWebhookWithManager registers the webhook for Epgconf in the manager.
+// config returns the operator configuration the Epgconfs are validated against.
+func SetupEpgconfWebhookWithManager(mgr ctrl.Manager, config func() controller.CniConfig) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&epgv1alpha1.Epgconf{}).
+		WithValidator(&EpgconfCustomValidator{Client: mgr.GetClient(), Config: config}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-epg-custom-aci-v1alpha1-epgconf,mutating=false,failurePolicy=fail,sideEffects=None,groups=epg.custom.aci,resources=epgconfs,verbs=create;update,versions=v1alpha1,name=vepgconf-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// EpgconfCustomValidator validates Epgconf resources when they are created or
+// updated.
+type EpgconfCustomValidator struct {
+	Client client.Reader
+	Config func() controller.CniConfig
+}
+
+var _ admission.CustomValidator = &EpgconfCustomValidator{}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *EpgconfCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	conf, ok := obj.(*epgv1alpha1.Epgconf)
+	if !ok {
+		return nil, fmt.Errorf("expected an Epgconf object but got %T", obj)
+	}
+	epgconflog.Info("Validation for Epgconf upon creation", "name", conf.GetName(), "namespace", conf.GetNamespace())
+
+	return nil, v.validateEpgconf(ctx, conf)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *EpgconfCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	conf, ok := newObj.(*epgv1alpha1.Epgconf)
+	if !ok {
+		return nil, fmt.Errorf("expected an Epgconf object for the newObj but got %T", newObj)
+	}
+	epgconflog.Info("Validation for Epgconf upon update", "name", conf.GetName(), "namespace", conf.GetNamespace())
+
+	// The finalizer must be removable even if the Epgconf is no longer valid.
+	if conf.GetDeletionTimestamp() != nil {
+		return nil, nil
+	}
+	return nil, v.validateEpgconf(ctx, conf)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *EpgconfCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *EpgconfCustomValidator) validateEpgconf(ctx context.Context, conf *epgv1alpha1.Epgconf) error {
+	config := v.Config()
+	var allErrs field.ErrorList
+
+	confs := &epgv1alpha1.EpgconfList{}
+	err := v.Client.List(ctx, confs, client.InNamespace(conf.GetNamespace()))
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	for _, other := range confs.Items {
+		if other.GetName() != conf.GetName() {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata", "namespace"),
+				fmt.Sprintf("Epgconf %s already configures the EPG of namespace %s", other.GetName(), conf.GetNamespace())))
+		}
+	}
+
+	epg, err := controller.ResolveEpg(conf, config)
+	if err != nil {
+		if conf.Spec.EpgName != "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "epgName"), conf.Spec.EpgName, err.Error()))
+		} else {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "namespace"), conf.GetNamespace(), err.Error()))
+		}
+	} else {
+		sharedErrs, err := v.validateSharedEpg(ctx, conf, epg, config)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, sharedErrs...)
+
+		for _, notAllowed := range controller.CheckAllowed(epg, config) {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", notAllowed.Field), notAllowed.Error()))
+		}
+	}
+
+	for i, contract := range conf.Spec.ProvidedContracts {
+		if err := aci.ValidateName(contract); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "providedContracts").Index(i), contract, err.Error()))
+		}
+	}
+	for i, contract := range conf.Spec.ConsumedContracts {
+		if err := aci.ValidateName(contract); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "consumedContracts").Index(i), contract, err.Error()))
+		}
+	}
+
+	for i, subnet := range conf.Spec.Subnets {
+		if _, _, err := net.ParseCIDR(subnet); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "subnets").Index(i), subnet, "must be a gateway address with prefix length, e.g. 10.10.0.1/24"))
+		}
+	}
+	if !conf.Spec.CreateMissingParents && (conf.Spec.Vrf != "" || len(conf.Spec.Subnets) > 0) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "createMissingParents"),
+			"vrf and subnets are only used with createMissingParents"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(epgv1alpha1.GroupVersion.WithKind("Epgconf").GroupKind(), conf.GetName(), allErrs)
+}
+
+// validateSharedEpg rejects an EPG used by an Epgconf in another namespace,
+// unless both are shared.
+func (v *EpgconfCustomValidator) validateSharedEpg(ctx context.Context, conf *epgv1alpha1.Epgconf, epg controller.Epg, config controller.CniConfig) (field.ErrorList, error) {
+	confs := &epgv1alpha1.EpgconfList{}
+	err := v.Client.List(ctx, confs)
+	if err != nil {
+		return nil, err
+	}
+
+	var allErrs field.ErrorList
+	for i, other := range confs.Items {
+		if other.GetNamespace() == conf.GetNamespace() || other.GetDeletionTimestamp() != nil {
+			continue
+		}
+		otherEpgDn := other.Status.EpgDn
+		if otherEpgDn == "" {
+			otherEpg, err := controller.ResolveEpg(&confs.Items[i], config)
+			if err != nil {
+				continue
+			}
+			otherEpgDn = otherEpg.Dn()
+		}
+		if otherEpgDn == epg.Dn() && !(conf.Spec.Shared && other.Spec.Shared) {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "shared"),
+				fmt.Sprintf("EPG %s is used by namespace %s, both Epgconfs must be shared", epg.Name, other.GetNamespace())))
+		}
+	}
+	return allErrs, nil
+}
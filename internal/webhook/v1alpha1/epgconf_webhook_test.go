@@ -0,0 +1,145 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	epgv1alpha1 "github.com/4ndersson/epg-config-operator/api/v1alpha1"
+	"github.com/4ndersson/epg-config-operator/internal/controller"
+)
+
+var _ = Describe("Epgconf Webhook", func() {
+	ctx := context.Background()
+
+	var (
+		config    controller.CniConfig
+		validator *EpgconfCustomValidator
+		obj       *epgv1alpha1.Epgconf
+	)
+
+	BeforeEach(func() {
+		config = controller.CniConfig{
+			Tenant:            "optest",
+			VmmDomain:         "ocpaci",
+			AllowedTenants:    []string{"optest"},
+			AllowedVmmDomains: []string{"ocpaci"},
+		}
+		existing := &epgv1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "ns-taken"},
+		}
+		shared := &epgv1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "ns-shared"},
+			Spec:       epgv1alpha1.EpgconfSpec{EpgName: "shared-epg", Shared: true},
+		}
+		validator = &EpgconfCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing, shared).Build(),
+			Config: func() controller.CniConfig { return config },
+		}
+		obj = &epgv1alpha1.Epgconf{
+			ObjectMeta: metav1.ObjectMeta{Name: "epgconf", Namespace: "ns-1"},
+			Spec: epgv1alpha1.EpgconfSpec{
+				ProvidedContracts: []string{"web"},
+				ConsumedContracts: []string{"db"},
+			},
+		}
+	})
+
+	It("Should admit a valid Epgconf", func() {
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should deny a second Epgconf in the namespace", func() {
+		obj.Namespace = "ns-taken"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).Should(MatchError(ContainSubstring("Epgconf existing already configures the EPG")))
+	})
+
+	It("Should only admit an EPG used by another namespace if both are shared", func() {
+		obj.Spec.EpgName = "shared-epg"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).Should(MatchError(ContainSubstring("EPG shared-epg is used by namespace ns-shared")))
+
+		obj.Spec.Shared = true
+		_, err = validator.ValidateCreate(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should deny names the APIC does not accept", func() {
+		obj.Spec.EpgName = strings.Repeat("a", 65)
+		obj.Spec.ConsumedContracts = []string{"not/valid"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).Should(MatchError(ContainSubstring("spec.epgName")))
+		Expect(err).Should(MatchError(ContainSubstring("spec.consumedContracts[0]")))
+	})
+
+	It("Should deny tenants that are not allowed", func() {
+		config.AllowedTenants = []string{"other"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).Should(MatchError(ContainSubstring("not allowed to configure tenant optest")))
+	})
+
+	It("Should only admit overrides that are allowed", func() {
+		config.AllowedApplicationProfiles = []string{"kubernetes"}
+		config.AllowedBridgeDomains = []string{"isolated"}
+		obj.Spec.Tenant = "other"
+		obj.Spec.ApplicationProfile = "apps"
+		obj.Spec.BridgeDomain = "isolated"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).Should(MatchError(ContainSubstring("spec.tenant")))
+		Expect(err).Should(MatchError(ContainSubstring("not allowed to configure application profile apps")))
+		Expect(err).ShouldNot(MatchError(ContainSubstring("spec.bridgeDomain")))
+
+		obj.Spec.Tenant = ""
+		obj.Spec.ApplicationProfile = "kubernetes"
+		_, err = validator.ValidateCreate(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should admit the name of a shared EPG in another application profile", func() {
+		obj.Spec.EpgName = "shared-epg"
+		obj.Spec.ApplicationProfile = "apps"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should deny invalid subnets and subnets without createMissingParents", func() {
+		obj.Spec.Subnets = []string{"10.10.0.1"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).Should(MatchError(ContainSubstring("spec.subnets[0]")))
+		Expect(err).Should(MatchError(ContainSubstring("only used with createMissingParents")))
+
+		obj.Spec.Subnets = []string{"10.10.0.1/24"}
+		obj.Spec.CreateMissingParents = true
+		_, err = validator.ValidateCreate(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should admit updates of an Epgconf being deleted", func() {
+		obj.Spec.EpgName = "not/valid"
+		obj.DeletionTimestamp = &metav1.Time{}
+		_, err := validator.ValidateUpdate(ctx, obj, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+})
@@ -1,161 +1,763 @@
 package aci
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	aciclient "github.com/ciscoecosystem/aci-go-client/client"
 	"github.com/ciscoecosystem/aci-go-client/models"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+var log = logf.Log.WithName("aci")
+
+const (
+	// DefaultRetries is the number of times an operation failing with a
+	// transient error is retried.
+	DefaultRetries = 3
+	// DefaultRetryBaseDelay is the delay before the first retry, it doubles
+	// for every retry.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay caps the delay between retries.
+	DefaultRetryMaxDelay = 10 * time.Second
+)
+
+// OwnerTagKey is the key of the tag on the objects the operator owns. On an EPG
+// the value is the UID of the owning cluster, on a contract or filter it is
+// followed by the namespace and name of the AciContract.
+const OwnerTagKey = "epg-config-operator-owner"
+
+// OrphanedTagKey is the key of the tag on the EPGs kept on the APIC after their
+// Epgconf was deleted, the value is the namespace and name of the Epgconf.
+const OrphanedTagKey = "epg-config-operator-orphaned"
+
+// ManagedAnnotation is set on the objects the operator creates on the APIC, so
+// that objects added by hand are left alone when reconciling.
+const ManagedAnnotation = "orchestrator:epg-config-operator"
+
+// ApicClient talks to the members of an APIC cluster. Operations are sent to
+// the active host and fail over to the next member when it is unreachable or
+// answers with a server error.
 type ApicClient struct {
-	host     string
-	user     string
-	password string
-	client   *aciclient.Client
+	hosts       []string
+	credentials Credentials
+	tlsConfig   *tls.Config
+	clients     []*aciclient.Client
+	lock        sync.Mutex
+	active      int
+
+	retries        int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
 }
 
 type ApicInterface interface {
-	CreateEpg(name, app, tenant, bd, vmm, vmmType string) error
+	CreateEpg(name, app, tenant, vmmType string) error
+	BindBridgeDomain(epgName, app, tenant, bd string) error
+	BindVmmDomain(epgName, app, tenant, vmm, vmmType string) error
 	DeleteEpg(name, app, tenant string) error
 	EpgExists(name, app, tenant string) (bool, error)
-	ConsumeContract(epgName, app, tenant, conName string) error
-	ProvideContract(epgName, app, tenant, conName string) error
-	GetConsumedContracts(epgName, app, tenant string) ([]string, error)
-	GetProvidedContracts(epgName, app, tenant string) ([]string, error)
+	ReadEpg(name, app, tenant string) (*Epg, error)
+	// The contract methods take the distinguished name of the EPG or ESG.
+	ConsumeContract(dn, conName string) error
+	ProvideContract(dn, conName string) error
+	GetConsumedContracts(dn string) ([]string, error)
+	GetProvidedContracts(dn string) ([]string, error)
+	GetManagedConsumedContracts(dn string) ([]string, error)
+	GetManagedProvidedContracts(dn string) ([]string, error)
+	RemoveConsumedContract(dn, conName string) error
+	RemoveProvidedContract(dn, conName string) error
+	GetEpgTag(epgName, app, tenant, key string) (string, error)
+	SetEpgTag(epgName, app, tenant, key, value string) error
+	RemoveEpgTag(epgName, app, tenant, key string) error
+	EnsureApplicationProfile(app, tenant string) (bool, error)
+	EnsureBridgeDomain(bd, tenant, vrf string, subnets []string) (bool, error)
+	EsgExists(name, app, tenant string) (bool, error)
+	CreateEsg(name, app, tenant, vrf string) error
+	DeleteEsg(name, app, tenant string) error
+	AddEpgSelector(esg, app, tenant, epgDn string) error
+	RemoveEpgSelector(esg, app, tenant, epgDn string) error
+	GetManagedTagSelectors(esg, app, tenant string) ([]TagSelector, error)
+	AddTagSelector(esg, app, tenant string, selector TagSelector) error
+	RemoveTagSelector(esg, app, tenant string, selector TagSelector) error
+	ApplyFilter(tenant string, filter Filter) error
+	DeleteFilter(name, tenant string) error
+	ApplyContract(tenant string, contract Contract) error
+	DeleteContract(name, tenant string) error
+	ContractExists(tenant, name string) (bool, error)
+	ReadOwnership(dn string) (Ownership, error)
+	SetTag(dn, key, value string) error
 }
 
-func NewClient(host, user, password, key string) (*ApicClient, error) {
-	ac := &ApicClient{
-		host:     host,
-		user:     user,
-		password: password,
-	}
-	if key == "" {
-		ac.client = aciclient.GetClient(fmt.Sprintf("https://%s/", host), user, aciclient.Password(password), aciclient.Insecure(true), aciclient.SkipLoggingPayload(true))
-	} else {
-		ac.client = aciclient.GetClient(fmt.Sprintf("https://%s/", host), user, aciclient.PrivateKey(key), aciclient.AdminCert(fmt.Sprintf("%s.crt", user)), aciclient.Insecure(true), aciclient.SkipLoggingPayload(true))
-	}
+// TagSelector matches the endpoints with the tag key and value into an ESG.
+type TagSelector struct {
+	Key   string
+	Value string
+}
+
+// Epg is the live configuration of an EPG on the APIC.
+type Epg struct {
+	Dn           string
+	Annotation   string
+	Description  string
+	BridgeDomain string
+	VmmDomains   []string
+}
 
-	_, err := ac.client.ListSystem()
+// EpgDescription is the description of the EPGs the operator creates.
+const EpgDescription = "created by kubernetes operator"
 
-	return ac, err
+// EpgAnnotation returns the annotation of the EPGs the operator creates for
+// the VMM domain type.
+func EpgAnnotation(vmmType string) string {
+	return fmt.Sprintf("orchestrator:%s", strings.ToLower(vmmType))
 }
 
-func (ac *ApicClient) CreateEpg(name, app, tenant, bd, vmm, vmmType string) error {
-	fvAEpgAttr := models.ApplicationEPGAttributes{}
-	fvAEpgAttr.Annotation = fmt.Sprintf("orchestrator:%s", strings.ToLower(vmmType))
-	fvAEpg := models.NewApplicationEPG(fmt.Sprintf("epg-%s", name), fmt.Sprintf("uni/tn-%s/ap-%s", tenant, app), "created by kubernetes operator", fvAEpgAttr)
+// Credentials authenticate the operator on the APIC, with the password of
+// the user or with the private key of a certificate of the user.
+type Credentials struct {
+	Username   string
+	Password   string
+	PrivateKey string
+	// CertificateName is the name of the certificate of the user on the
+	// APIC, <username>.crt when empty.
+	CertificateName string
+}
 
-	err := ac.client.Save(fvAEpg)
-	if err != nil {
-		return err
+// certificateName returns the name of the certificate of the user on the APIC.
+func (c Credentials) certificateName() string {
+	if c.CertificateName != "" {
+		return c.CertificateName
 	}
+	return fmt.Sprintf("%s.crt", c.Username)
+}
 
-	err = ac.client.CreateRelationfvRsBdFromApplicationEPG(fvAEpg.DistinguishedName, bd)
-	if err != nil {
-		return err
+// Type describes the credentials for error messages, a certificate is named
+// by its distinguished name on the APIC.
+func (c Credentials) Type() string {
+	if c.PrivateKey == "" {
+		return fmt.Sprintf("password of user %s", c.Username)
 	}
+	return fmt.Sprintf("private key of certificate uni/userext/user-%s/usercert-%s", c.Username, c.certificateName())
+}
 
-	// Does not return error if not bound correctly
-	err = ac.client.CreateRelationfvRsDomAttFromApplicationEPG(fvAEpg.DistinguishedName, fmt.Sprintf("uni/vmmp-%s/dom-%s", vmmType, vmm))
+func NewClient(hosts []string, credentials Credentials, tlsOptions TLSOptions) (*ApicClient, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no APIC hosts configured")
+	}
+	tlsConfig, err := tlsOptions.tlsConfig()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	ac := &ApicClient{
+		hosts:          hosts,
+		credentials:    credentials,
+		tlsConfig:      tlsConfig,
+		clients:        newHostClients(hosts, credentials, tlsConfig),
+		retries:        DefaultRetries,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		retryMaxDelay:  DefaultRetryMaxDelay,
+	}
+	setActiveHost(hosts, 0)
+
+	return ac, ac.checkLogin()
+}
+
+// SetCredentials logs in with the new credentials and, once they are
+// accepted, replaces the clients of all hosts with clients using them. Rejected
+// credentials leave the client as it was. Operations in flight finish with the
+// old clients.
+func (ac *ApicClient) SetCredentials(credentials Credentials) error {
+	ac.lock.Lock()
+	candidate := &ApicClient{
+		hosts:          ac.hosts,
+		credentials:    credentials,
+		tlsConfig:      ac.tlsConfig,
+		clients:        newHostClients(ac.hosts, credentials, ac.tlsConfig),
+		active:         ac.active,
+		retries:        ac.retries,
+		retryBaseDelay: ac.retryBaseDelay,
+		retryMaxDelay:  ac.retryMaxDelay,
 	}
+	ac.lock.Unlock()
 
-	_, err = ac.client.ReadRelationfvRsDomAttFromApplicationEPG(fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name))
+	err := candidate.checkLogin()
 	if err != nil {
 		return err
 	}
+
+	candidate.lock.Lock()
+	defer candidate.lock.Unlock()
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	ac.credentials = candidate.credentials
+	ac.clients = candidate.clients
+	ac.active = candidate.active
 	return nil
 }
 
-func (ac *ApicClient) DeleteEpg(name, app, tenant string) error {
-	err := ac.client.DeleteApplicationEPG(name, app, tenant)
-	if err != nil {
+// checkLogin logs in on the APIC with a cheap read. A rejected login names
+// the credentials that failed.
+func (ac *ApicClient) checkLogin() error {
+	err := ac.do("ListSystem", func(c *aciclient.Client) error {
+		_, err := c.ListSystem()
 		return err
+	})
+	if errors.Is(err, ErrAuthFailed) {
+		ac.lock.Lock()
+		credentials := ac.credentials
+		ac.lock.Unlock()
+		return fmt.Errorf("APIC rejected the %s: %w", credentials.Type(), err)
 	}
-	return nil
+	return err
 }
 
-func (ac *ApicClient) EpgExists(name, app, tenant string) (bool, error) {
+// CheckConnection checks that the APIC cluster can be reached and accepts
+// the credentials.
+func (ac *ApicClient) CheckConnection() error {
+	return ac.checkLogin()
+}
 
-	fvAEPgCont, err := ac.client.Get(fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name))
-	if err != nil {
-		return false, err
+// renewSession replaces the client of the host with a new one, which logs in
+// again on its next request, and returns it. The aci-go-client only renews its
+// token when it expires, not when the APIC drops the session earlier. Requests
+// signed with a private key have no session and nil is returned.
+func (ac *ApicClient) renewSession(host int) *aciclient.Client {
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	if ac.credentials.PrivateKey != "" {
+		return nil
+	}
+	clients := slices.Clone(ac.clients)
+	clients[host] = newHostClients(ac.hosts[host:host+1], ac.credentials, ac.tlsConfig)[0]
+	ac.clients = clients
+	return clients[host]
+}
+
+func newHostClients(hosts []string, credentials Credentials, tlsConfig *tls.Config) []*aciclient.Client {
+	clients := []*aciclient.Client{}
+	for _, host := range hosts {
+		httpClient := &http.Client{Transport: &failoverTransport{next: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig.Clone(),
+		}}}
+		if credentials.PrivateKey == "" {
+			clients = append(clients, aciclient.NewClient(fmt.Sprintf("https://%s/", host), credentials.Username, aciclient.Password(credentials.Password), aciclient.HttpClient(httpClient), aciclient.SkipLoggingPayload(true)))
+		} else {
+			clients = append(clients, aciclient.NewClient(fmt.Sprintf("https://%s/", host), credentials.Username, aciclient.PrivateKey(credentials.PrivateKey), aciclient.AdminCert(credentials.certificateName()), aciclient.HttpClient(httpClient), aciclient.SkipLoggingPayload(true)))
+		}
 	}
-	fvAEPg := models.ApplicationEPGFromContainer(fvAEPgCont)
+	return clients
+}
 
-	if fvAEPg.DistinguishedName == "" {
-		return false, nil
+// ActiveHost returns the APIC host operations are currently sent to.
+func (ac *ApicClient) ActiveHost() string {
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	return ac.hosts[ac.active]
+}
+
+// do runs an operation against the APIC and records its metrics. Transient
+// and throttling errors are retried with backoff, the error returned is an
+// *ApicError classifying the failure.
+func (ac *ApicClient) do(operation string, fn func(c *aciclient.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		err := ac.tryHosts(operation, fn)
+		if err == nil {
+			return nil
+		}
+		apicErr := &ApicError{Operation: operation, Class: classify(err), Err: err}
+		if !retryable(apicErr) || attempt >= ac.retries {
+			return apicErr
+		}
+		delay := backoff(attempt, ac.retryBaseDelay, ac.retryMaxDelay)
+		log.V(1).Info("Retrying APIC operation", "operation", operation, "attempt", attempt+1, "delay", delay.String(), "error", err.Error())
+		time.Sleep(delay)
 	}
-	return true, nil
 }
 
-func (ac *ApicClient) ConsumeContract(epg, app, tenant, contract string) error {
+// tryHosts runs an operation against the active APIC. If the host cannot be
+// reached the operation is tried on the other members of the cluster, the
+// first one that answers becomes the active host. A session the APIC no
+// longer accepts is renewed once.
+func (ac *ApicClient) tryHosts(operation string, fn func(c *aciclient.Client) error) error {
+	ac.lock.Lock()
+	active := ac.active
+	clients := ac.clients
+	ac.lock.Unlock()
 
-	fvRsConsAtt := models.ContractConsumerAttributes{}
-	fvRsConsAtt.TnVzBrCPName = contract
-	fvRsCons := models.NewContractConsumer(fmt.Sprintf("rscons-%s", contract), fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg), fvRsConsAtt)
+	var err error
+	for i := range ac.hosts {
+		host := (active + i) % len(ac.hosts)
+		start := time.Now()
+		err = fn(clients[host])
+		observeRequest(operation, ac.hosts[host], start, err)
+		if err != nil && classify(err) == ErrAuthFailed {
+			if renewed := ac.renewSession(host); renewed != nil {
+				log.Info("APIC rejected the session, logging in again", "host", ac.hosts[host], "operation", operation)
+				start = time.Now()
+				err = fn(renewed)
+				observeRequest(operation, ac.hosts[host], start, err)
+			}
+		}
+		if err == nil || classify(err) != ErrTransient {
+			ac.setActive(host)
+			return err
+		}
+		log.Info("APIC is unavailable", "host", ac.hosts[host], "operation", operation, "error", err.Error())
+	}
+	return err
+}
 
-	err := ac.client.Save(fvRsCons)
-	if err != nil {
-		return err
+func (ac *ApicClient) setActive(host int) {
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	if ac.active == host {
+		return
 	}
-	return nil
+	log.Info("Failing over to APIC", "from", ac.hosts[ac.active], "to", ac.hosts[host])
+	ac.active = host
+	apicFailovers.Inc()
+	setActiveHost(ac.hosts, host)
 }
 
-func (ac *ApicClient) ProvideContract(epg, app, tenant, contract string) error {
+var (
+	epgDnPattern = regexp.MustCompile(`^uni/tn-([^/]+)/ap-([^/]+)/epg-([^/]+)$`)
+	esgDnPattern = regexp.MustCompile(`^uni/tn-([^/]+)/ap-([^/]+)/esg-([^/]+)$`)
+)
+
+// EpgDn returns the distinguished name of an EPG on the APIC.
+func EpgDn(name, app, tenant string) string {
+	return fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name)
+}
 
-	fvRsProvAtt := models.ContractProviderAttributes{}
-	fvRsProvAtt.TnVzBrCPName = contract
-	fvRsCons := models.NewContractProvider(fmt.Sprintf("rsprov-%s", contract), fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg), fvRsProvAtt)
+// EsgDn returns the distinguished name of an endpoint security group on the
+// APIC.
+func EsgDn(name, app, tenant string) string {
+	return fmt.Sprintf("uni/tn-%s/ap-%s/esg-%s", tenant, app, name)
+}
 
-	err := ac.client.Save(fvRsCons)
-	if err != nil {
-		return err
+// ParseEpgDn returns the name, application profile and tenant of the EPG with
+// the distinguished name.
+func ParseEpgDn(dn string) (string, string, string, error) {
+	match := epgDnPattern.FindStringSubmatch(dn)
+	if match == nil {
+		return "", "", "", fmt.Errorf("invalid EPG distinguished name %q", dn)
 	}
-	return nil
+	return match[3], match[2], match[1], nil
 }
 
-func (ac *ApicClient) GetConsumedContracts(epg, app, tenant string) ([]string, error) {
-	baseurlStr := "/api/node/class"
-	cont, err := ac.client.GetViaURL(fmt.Sprintf("%s/uni/tn-%s/ap-%s/epg-%s/fvRsCons.json", baseurlStr, tenant, app, epg))
+// ParseEsgDn returns the name, application profile and tenant of the ESG with
+// the distinguished name.
+func ParseEsgDn(dn string) (string, string, string, error) {
+	match := esgDnPattern.FindStringSubmatch(dn)
+	if match == nil {
+		return "", "", "", fmt.Errorf("invalid ESG distinguished name %q", dn)
+	}
+	return match[3], match[2], match[1], nil
+}
 
-	if err != nil {
-		if strings.Contains(err.Error(), "may not exists") {
-			return []string{}, nil
-		} else {
-			return []string{}, err
+// VmmDomainDn returns the distinguished name of a VMM domain on the APIC.
+func VmmDomainDn(vmm, vmmType string) string {
+	return fmt.Sprintf("uni/vmmp-%s/dom-%s", vmmType, vmm)
+}
+
+// ApplicationProfileDn returns the distinguished name of an application profile
+// on the APIC.
+func ApplicationProfileDn(app, tenant string) string {
+	return fmt.Sprintf("uni/tn-%s/ap-%s", tenant, app)
+}
+
+// BridgeDomainDn returns the distinguished name of a bridge domain on the APIC.
+func BridgeDomainDn(bd, tenant string) string {
+	return fmt.Sprintf("uni/tn-%s/BD-%s", tenant, bd)
+}
+
+func (ac *ApicClient) CreateEpg(name, app, tenant, vmmType string) error {
+	return ac.do("CreateEpg", func(c *aciclient.Client) error {
+		fvAEpgAttr := models.ApplicationEPGAttributes{}
+		fvAEpgAttr.Annotation = EpgAnnotation(vmmType)
+		fvAEpg := models.NewApplicationEPG(fmt.Sprintf("epg-%s", name), fmt.Sprintf("uni/tn-%s/ap-%s", tenant, app), EpgDescription, fvAEpgAttr)
+
+		return c.Save(fvAEpg)
+	})
+}
+
+func (ac *ApicClient) BindBridgeDomain(epg, app, tenant, bd string) error {
+	return ac.do("BindBridgeDomain", func(c *aciclient.Client) error {
+		return c.CreateRelationfvRsBdFromApplicationEPG(EpgDn(epg, app, tenant), bd)
+	})
+}
+
+func (ac *ApicClient) BindVmmDomain(epg, app, tenant, vmm, vmmType string) error {
+	return ac.do("BindVmmDomain", func(c *aciclient.Client) error {
+		// Does not return error if not bound correctly
+		err := c.CreateRelationfvRsDomAttFromApplicationEPG(EpgDn(epg, app, tenant), VmmDomainDn(vmm, vmmType))
+		if err != nil {
+			return err
 		}
-	}
 
-	contracts := models.ContractConsumerListFromContainer(cont)
-	contractsParsed := make([]string, len(models.ContractConsumerListFromContainer(cont)))
+		_, err = c.ReadRelationfvRsDomAttFromApplicationEPG(EpgDn(epg, app, tenant))
+		return err
+	})
+}
 
-	for i, contract := range contracts {
-		contractsParsed[i] = contract.TnVzBrCPName
-	}
-	return contractsParsed, nil
+func (ac *ApicClient) DeleteEpg(name, app, tenant string) error {
+	return ac.do("DeleteEpg", func(c *aciclient.Client) error {
+		return c.DeleteApplicationEPG(name, app, tenant)
+	})
 }
 
-func (ac *ApicClient) GetProvidedContracts(epg, app, tenant string) ([]string, error) {
-	baseurlStr := "/api/node/class"
-	cont, err := ac.client.GetViaURL(fmt.Sprintf("%s/uni/tn-%s/ap-%s/epg-%s/fvRsProv.json", baseurlStr, tenant, app, epg))
+func (ac *ApicClient) EpgExists(name, app, tenant string) (bool, error) {
+	exists := false
+	err := ac.do("EpgExists", func(c *aciclient.Client) error {
+		fvAEPgCont, err := c.Get(EpgDn(name, app, tenant))
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		fvAEPg := models.ApplicationEPGFromContainer(fvAEPgCont)
+		exists = fvAEPg.DistinguishedName != ""
+		return nil
+	})
+	return exists, err
+}
+
+// ReadEpg returns the EPG with its bridge domain and domain attachments as
+// configured on the APIC, or nil if the EPG does not exist.
+func (ac *ApicClient) ReadEpg(name, app, tenant string) (*Epg, error) {
+	var epg *Epg
+	err := ac.do("ReadEpg", func(c *aciclient.Client) error {
+		dn := EpgDn(name, app, tenant)
+		fvAEPgCont, err := c.Get(dn)
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		fvAEPg := models.ApplicationEPGFromContainer(fvAEPgCont)
+		live := &Epg{Dn: dn, Annotation: fvAEPg.Annotation, Description: fvAEPg.Description}
+
+		baseurlStr := "/api/node/class"
+		bdCont, err := c.GetViaURL(fmt.Sprintf("%s/%s/fvRsBd.json", baseurlStr, dn))
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		if err == nil {
+			bds := models.ListFromContainer(bdCont, "fvRsBd")
+			if len(bds) > 0 {
+				live.BridgeDomain = models.G(bds[0], "tnFvBDName")
+			}
+		}
+
+		domCont, err := c.GetViaURL(fmt.Sprintf("%s/%s/fvRsDomAtt.json", baseurlStr, dn))
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		if err == nil {
+			for _, dom := range models.ListFromContainer(domCont, "fvRsDomAtt") {
+				live.VmmDomains = append(live.VmmDomains, models.G(dom, "tDn"))
+			}
+		}
+		epg = live
+		return nil
+	})
+	return epg, err
+}
+
+func (ac *ApicClient) ConsumeContract(dn, contract string) error {
+	return ac.do("ConsumeContract", func(c *aciclient.Client) error {
+		fvRsConsAtt := models.ContractConsumerAttributes{}
+		fvRsConsAtt.TnVzBrCPName = contract
+		fvRsConsAtt.Annotation = ManagedAnnotation
+		fvRsCons := models.NewContractConsumer(fmt.Sprintf("rscons-%s", contract), dn, fvRsConsAtt)
+
+		return c.Save(fvRsCons)
+	})
+}
+
+func (ac *ApicClient) ProvideContract(dn, contract string) error {
+	return ac.do("ProvideContract", func(c *aciclient.Client) error {
+		fvRsProvAtt := models.ContractProviderAttributes{}
+		fvRsProvAtt.TnVzBrCPName = contract
+		fvRsProvAtt.Annotation = ManagedAnnotation
+		fvRsProv := models.NewContractProvider(fmt.Sprintf("rsprov-%s", contract), dn, fvRsProvAtt)
+
+		return c.Save(fvRsProv)
+	})
+}
+
+func (ac *ApicClient) GetConsumedContracts(dn string) ([]string, error) {
+	return ac.getContracts("GetConsumedContracts", fmt.Sprintf("/api/node/class/%s/fvRsCons.json", dn), models.FvrsconsClassName)
+}
 
+func (ac *ApicClient) GetProvidedContracts(dn string) ([]string, error) {
+	return ac.getContracts("GetProvidedContracts", fmt.Sprintf("/api/node/class/%s/fvRsProv.json", dn), models.FvrsprovClassName)
+}
+
+func (ac *ApicClient) GetManagedConsumedContracts(dn string) ([]string, error) {
+	return ac.getContracts("GetManagedConsumedContracts", fmt.Sprintf("/api/node/class/%s/fvRsCons.json?query-target-filter=eq(fvRsCons.annotation,\"%s\")",
+		dn, ManagedAnnotation), models.FvrsconsClassName)
+}
+
+func (ac *ApicClient) GetManagedProvidedContracts(dn string) ([]string, error) {
+	return ac.getContracts("GetManagedProvidedContracts", fmt.Sprintf("/api/node/class/%s/fvRsProv.json?query-target-filter=eq(fvRsProv.annotation,\"%s\")",
+		dn, ManagedAnnotation), models.FvrsprovClassName)
+}
+
+// getContracts returns the contract names of the fvRsCons or fvRsProv
+// relations found at the url.
+func (ac *ApicClient) getContracts(operation, url, className string) ([]string, error) {
+	contractsParsed := []string{}
+	err := ac.do(operation, func(c *aciclient.Client) error {
+		cont, err := c.GetViaURL(url)
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		for _, contract := range models.ListFromContainer(cont, className) {
+			contractsParsed = append(contractsParsed, models.G(contract, "tnVzBrCPName"))
+		}
+		return nil
+	})
+	return contractsParsed, err
+}
+
+func (ac *ApicClient) RemoveConsumedContract(dn, contract string) error {
+	return ac.do("RemoveConsumedContract", func(c *aciclient.Client) error {
+		return c.DeleteByDn(fmt.Sprintf("%s/rscons-%s", dn, contract), models.FvrsconsClassName)
+	})
+}
+
+func (ac *ApicClient) RemoveProvidedContract(dn, contract string) error {
+	return ac.do("RemoveProvidedContract", func(c *aciclient.Client) error {
+		return c.DeleteByDn(fmt.Sprintf("%s/rsprov-%s", dn, contract), models.FvrsprovClassName)
+	})
+}
+
+// GetEpgTag returns the value of the tagAnnotation with the key on the EPG, or
+// an empty string if the EPG has no such tag.
+func (ac *ApicClient) GetEpgTag(epg, app, tenant, key string) (string, error) {
+	value := ""
+	err := ac.do("GetEpgTag", func(c *aciclient.Client) error {
+		tag, err := c.ReadAnnotation(key, EpgDn(epg, app, tenant))
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		value = tag.Value
+		return nil
+	})
+	return value, err
+}
+
+// SetEpgTag sets a tagAnnotation on the EPG.
+func (ac *ApicClient) SetEpgTag(epg, app, tenant, key, value string) error {
+	return ac.do("SetEpgTag", func(c *aciclient.Client) error {
+		_, err := c.CreateAnnotation(key, EpgDn(epg, app, tenant), models.AnnotationAttributes{Key: key, Value: value})
+		return err
+	})
+}
+
+// SetTag sets a tagAnnotation on the object with the dn.
+func (ac *ApicClient) SetTag(dn, key, value string) error {
+	return ac.do("SetTag", func(c *aciclient.Client) error {
+		_, err := c.CreateAnnotation(key, dn, models.AnnotationAttributes{Key: key, Value: value})
+		return err
+	})
+}
+
+// RemoveEpgTag removes a tagAnnotation from the EPG.
+func (ac *ApicClient) RemoveEpgTag(epg, app, tenant, key string) error {
+	return ac.do("RemoveEpgTag", func(c *aciclient.Client) error {
+		return c.DeleteByDn(fmt.Sprintf("%s/%s", EpgDn(epg, app, tenant), fmt.Sprintf(models.RnTagAnnotation, key)), models.TagAnnotationClassName)
+	})
+}
+
+// EnsureApplicationProfile creates the application profile if it does not
+// exist and reports if it was created.
+func (ac *ApicClient) EnsureApplicationProfile(app, tenant string) (bool, error) {
+	created := false
+	err := ac.do("EnsureApplicationProfile", func(c *aciclient.Client) error {
+		exists, err := objectExists(c, ApplicationProfileDn(app, tenant))
+		if err != nil || exists {
+			return err
+		}
+
+		fvApAttr := models.ApplicationProfileAttributes{}
+		fvApAttr.Annotation = ManagedAnnotation
+		fvAp := models.NewApplicationProfile(fmt.Sprintf("ap-%s", app), fmt.Sprintf("uni/tn-%s", tenant), "created by kubernetes operator", fvApAttr)
+		err = c.Save(fvAp)
+		if err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// EnsureBridgeDomain creates the bridge domain in the VRF if it does not exist
+// and reports if it was created. The subnets missing on the bridge domain are
+// added, existing subnets and the VRF of an existing bridge domain are left
+// as they are.
+func (ac *ApicClient) EnsureBridgeDomain(bd, tenant, vrf string, subnets []string) (bool, error) {
+	created := false
+	err := ac.do("EnsureBridgeDomain", func(c *aciclient.Client) error {
+		bdDn := BridgeDomainDn(bd, tenant)
+		exists, err := objectExists(c, bdDn)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fvBDAttr := models.BridgeDomainAttributes{}
+			fvBDAttr.Annotation = ManagedAnnotation
+			fvBD := models.NewBridgeDomain(fmt.Sprintf("BD-%s", bd), fmt.Sprintf("uni/tn-%s", tenant), "created by kubernetes operator", fvBDAttr)
+			err = c.Save(fvBD)
+			if err != nil {
+				return err
+			}
+			err = c.CreateRelationfvRsCtxFromBridgeDomain(bdDn, vrf)
+			if err != nil {
+				return err
+			}
+			created = true
+		}
+
+		for _, subnet := range subnets {
+			subnetDn := fmt.Sprintf("%s/subnet-[%s]", bdDn, subnet)
+			exists, err := objectExists(c, subnetDn)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			fvSubnetAttr := models.SubnetAttributes{}
+			fvSubnetAttr.Ip = subnet
+			fvSubnetAttr.Annotation = ManagedAnnotation
+			fvSubnet := models.NewSubnet(fmt.Sprintf("subnet-[%s]", subnet), bdDn, "created by kubernetes operator", fvSubnetAttr)
+			err = c.Save(fvSubnet)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// objectExists reports if the object with the distinguished name exists on the
+// APIC.
+func objectExists(c *aciclient.Client, dn string) (bool, error) {
+	cont, err := c.Get(dn)
 	if err != nil {
-		if strings.Contains(err.Error(), "may not exists") {
-			return []string{}, nil
-		} else {
-			return []string{}, err
+		if isNotFound(err) {
+			return false, nil
 		}
+		return false, err
 	}
+	return models.G(cont, "totalCount") != "0", nil
+}
 
-	contracts := models.ContractProviderListFromContainer(cont)
-	contractsParsed := make([]string, len(contracts))
+func (ac *ApicClient) EsgExists(name, app, tenant string) (bool, error) {
+	exists := false
+	err := ac.do("EsgExists", func(c *aciclient.Client) error {
+		var err error
+		exists, err = objectExists(c, EsgDn(name, app, tenant))
+		return err
+	})
+	return exists, err
+}
 
-	for i, contract := range contracts {
-		contractsParsed[i] = contract.TnVzBrCPName
-	}
-	return contractsParsed, nil
+// CreateEsg creates the endpoint security group in the VRF.
+func (ac *ApicClient) CreateEsg(name, app, tenant, vrf string) error {
+	return ac.do("CreateEsg", func(c *aciclient.Client) error {
+		fvESgAttr := models.EndpointSecurityGroupAttributes{}
+		fvESgAttr.Annotation = ManagedAnnotation
+		fvESg := models.NewEndpointSecurityGroup(fmt.Sprintf(models.RnfvESg, name), ApplicationProfileDn(app, tenant), "created by kubernetes operator", "", fvESgAttr)
+		err := c.Save(fvESg)
+		if err != nil {
+			return err
+		}
+		return c.CreateRelationfvRsScope(EsgDn(name, app, tenant), ManagedAnnotation, vrf)
+	})
+}
+
+func (ac *ApicClient) DeleteEsg(name, app, tenant string) error {
+	return ac.do("DeleteEsg", func(c *aciclient.Client) error {
+		return c.DeleteEndpointSecurityGroup(name, app, tenant)
+	})
+}
+
+// AddEpgSelector adds the endpoints of the EPG to the ESG.
+func (ac *ApicClient) AddEpgSelector(esg, app, tenant, epgDn string) error {
+	return ac.do("AddEpgSelector", func(c *aciclient.Client) error {
+		fvEPgSelectorAttr := models.EndpointSecurityGroupEPgSelectorAttributes{}
+		fvEPgSelectorAttr.Annotation = ManagedAnnotation
+		fvEPgSelectorAttr.MatchEpgDn = epgDn
+		fvEPgSelector := models.NewEndpointSecurityGroupEPgSelector(fmt.Sprintf(models.RnfvEPgSelector, epgDn), EsgDn(esg, app, tenant), "", "", fvEPgSelectorAttr)
+		return c.Save(fvEPgSelector)
+	})
+}
+
+func (ac *ApicClient) RemoveEpgSelector(esg, app, tenant, epgDn string) error {
+	return ac.do("RemoveEpgSelector", func(c *aciclient.Client) error {
+		return c.DeleteEndpointSecurityGroupEPgSelector(epgDn, esg, app, tenant)
+	})
+}
+
+// GetManagedTagSelectors returns the tag selectors of the ESG created by the
+// operator.
+func (ac *ApicClient) GetManagedTagSelectors(esg, app, tenant string) ([]TagSelector, error) {
+	selectors := []TagSelector{}
+	err := ac.do("GetManagedTagSelectors", func(c *aciclient.Client) error {
+		cont, err := c.GetViaURL(fmt.Sprintf("/api/node/class/%s/fvTagSelector.json?query-target-filter=eq(fvTagSelector.annotation,\"%s\")",
+			EsgDn(esg, app, tenant), ManagedAnnotation))
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		for _, selector := range models.ListFromContainer(cont, models.FvtagselectorClassName) {
+			selectors = append(selectors, TagSelector{Key: models.G(selector, "matchKey"), Value: models.G(selector, "matchValue")})
+		}
+		return nil
+	})
+	return selectors, err
+}
+
+// AddTagSelector adds the endpoints with the tag to the ESG.
+func (ac *ApicClient) AddTagSelector(esg, app, tenant string, selector TagSelector) error {
+	return ac.do("AddTagSelector", func(c *aciclient.Client) error {
+		fvTagSelectorAttr := models.EndpointSecurityGroupTagSelectorAttributes{}
+		fvTagSelectorAttr.Annotation = ManagedAnnotation
+		fvTagSelectorAttr.MatchKey = selector.Key
+		fvTagSelectorAttr.MatchValue = selector.Value
+		fvTagSelector := models.NewEndpointSecurityGroupTagSelector(fmt.Sprintf(models.RnfvTagSelector, selector.Key, selector.Value), EsgDn(esg, app, tenant), "", "", fvTagSelectorAttr)
+		return c.Save(fvTagSelector)
+	})
+}
+
+func (ac *ApicClient) RemoveTagSelector(esg, app, tenant string, selector TagSelector) error {
+	return ac.do("RemoveTagSelector", func(c *aciclient.Client) error {
+		return c.DeleteEndpointSecurityGroupTagSelector(selector.Value, selector.Key, esg, app, tenant)
+	})
 }
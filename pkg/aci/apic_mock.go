@@ -4,34 +4,89 @@ import (
 	"fmt"
 
 	"github.com/4ndersson/epg-config-operator/pkg/utils"
+	"github.com/samber/lo"
 )
 
 type endpointGroup struct {
-	name      string
-	tenant    string
-	app       string
-	Bd        string
-	Vmm       string
-	VmmType   string
-	contracts map[string][]string
+	name       string
+	tenant     string
+	app        string
+	annotation string
+	descr      string
+	Bd         string
+	Vmm        string
+	VmmType    string
+	contracts  map[string][]string
+	managed    map[string][]string
+	tags       map[string]string
+}
+
+type bridgeDomain struct {
+	Vrf     string
+	Subnets []string
+}
+
+type securityGroup struct {
+	Vrf          string
+	EpgSelectors []string
+	TagSelectors []TagSelector
 }
 
 type ApicClientMocks struct {
-	endpointGroups map[string]endpointGroup
+	contracts           map[string]Contract
+	missingContracts    map[string]bool
+	unmanaged           map[string]bool
+	tags                map[string]map[string]string
+	filters             map[string]Filter
+	securityGroups      map[string]securityGroup
+	endpointGroups      map[string]endpointGroup
+	applicationProfiles map[string]bool
+	bridgeDomains       map[string]bridgeDomain
 }
 
 func init() {
 	ApicMockClient.endpointGroups = map[string]endpointGroup{}
+	ApicMockClient.applicationProfiles = map[string]bool{}
+	ApicMockClient.bridgeDomains = map[string]bridgeDomain{}
+	ApicMockClient.securityGroups = map[string]securityGroup{}
+	ApicMockClient.contracts = map[string]Contract{}
+	ApicMockClient.filters = map[string]Filter{}
+	ApicMockClient.missingContracts = map[string]bool{}
+	ApicMockClient.unmanaged = map[string]bool{}
+	ApicMockClient.tags = map[string]map[string]string{}
 }
 
 var (
 	ApicMockClient ApicClientMocks
 )
 
-func (ac *ApicClientMocks) CreateEpg(name, app, tenant, bd, vmm, vmmType string) error {
+func (ac *ApicClientMocks) CreateEpg(name, app, tenant, vmmType string) error {
 	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name)
 	fmt.Printf("Creating EPG %s \n", dn)
-	ac.endpointGroups[dn] = endpointGroup{name: name, app: app, tenant: tenant, Bd: bd, VmmType: vmmType, Vmm: vmm, contracts: map[string][]string{}}
+	if _, exists := ac.endpointGroups[dn]; exists {
+		return nil
+	}
+	ac.endpointGroups[dn] = endpointGroup{name: name, app: app, tenant: tenant, annotation: EpgAnnotation(vmmType), descr: EpgDescription, VmmType: vmmType,
+		contracts: map[string][]string{}, managed: map[string][]string{}, tags: map[string]string{}}
+	return nil
+}
+
+func (ac *ApicClientMocks) BindBridgeDomain(epg, app, tenant, bd string) error {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Binding EPG %s to BD %s\n", dn, bd)
+	endpointGroup := ac.endpointGroups[dn]
+	endpointGroup.Bd = bd
+	ac.endpointGroups[dn] = endpointGroup
+	return nil
+}
+
+func (ac *ApicClientMocks) BindVmmDomain(epg, app, tenant, vmm, vmmType string) error {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Binding EPG %s to VMM domain %s\n", dn, vmm)
+	endpointGroup := ac.endpointGroups[dn]
+	endpointGroup.Vmm = vmm
+	endpointGroup.VmmType = vmmType
+	ac.endpointGroups[dn] = endpointGroup
 	return nil
 }
 
@@ -50,20 +105,34 @@ func (ac *ApicClientMocks) EpgExists(name, app, tenant string) (bool, error) {
 	return exists, nil
 }
 
-func (ac *ApicClientMocks) ConsumeContract(epg, app, tenant, contract string) error {
-	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
-	fmt.Printf("EPG %s consuming contract %s\n", dn, contract)
+func (ac *ApicClientMocks) ReadEpg(name, app, tenant string) (*Epg, error) {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name)
+	fmt.Printf("Reading EPG %s\n", dn)
+	endpointGroup, exists := ac.endpointGroups[dn]
+	if !exists {
+		return nil, nil
+	}
+	epg := &Epg{Dn: dn, Annotation: endpointGroup.annotation, Description: endpointGroup.descr, BridgeDomain: endpointGroup.Bd}
+	if endpointGroup.Vmm != "" {
+		epg.VmmDomains = []string{VmmDomainDn(endpointGroup.Vmm, endpointGroup.VmmType)}
+	}
+	return epg, nil
+}
+
+func (ac *ApicClientMocks) ConsumeContract(dn, contract string) error {
+	fmt.Printf("%s consuming contract %s\n", dn, contract)
 	if !utils.Contains(ac.endpointGroups[dn].contracts["consumed"], contract) {
 		ac.endpointGroups[dn].contracts["consumed"] = append(ac.endpointGroups[dn].contracts["consumed"], contract)
+		ac.endpointGroups[dn].managed["consumed"] = append(ac.endpointGroups[dn].managed["consumed"], contract)
 	}
 	return nil
 }
 
-func (ac *ApicClientMocks) ProvideContract(epg, app, tenant, contract string) error {
-	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
-	fmt.Printf("EPG %s providing contract %s\n", dn, contract)
+func (ac *ApicClientMocks) ProvideContract(dn, contract string) error {
+	fmt.Printf("%s providing contract %s\n", dn, contract)
 	if !utils.Contains(ac.endpointGroups[dn].contracts["provided"], contract) {
 		ac.endpointGroups[dn].contracts["provided"] = append(ac.endpointGroups[dn].contracts["provided"], contract)
+		ac.endpointGroups[dn].managed["provided"] = append(ac.endpointGroups[dn].managed["provided"], contract)
 	}
 	return nil
 }
@@ -74,14 +143,279 @@ func (ac *ApicClientMocks) GetEpg(name, app, tenant string) endpointGroup {
 	return ac.endpointGroups[dn]
 }
 
-func (ac *ApicClientMocks) GetConsumedContracts(name, app, tenant string) ([]string, error) {
-	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name)
-	fmt.Printf("Getting EPG %s \n", dn)
+func (ac *ApicClientMocks) GetConsumedContracts(dn string) ([]string, error) {
+	fmt.Printf("Getting contracts of %s \n", dn)
 	return ac.endpointGroups[dn].contracts["consumed"], nil
 }
 
-func (ac *ApicClientMocks) GetProvidedContracts(name, app, tenant string) ([]string, error) {
-	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, name)
-	fmt.Printf("Getting EPG %s \n", dn)
+func (ac *ApicClientMocks) GetProvidedContracts(dn string) ([]string, error) {
+	fmt.Printf("Getting contracts of %s \n", dn)
 	return ac.endpointGroups[dn].contracts["provided"], nil
 }
+
+func (ac *ApicClientMocks) GetManagedConsumedContracts(dn string) ([]string, error) {
+	fmt.Printf("Getting contracts of %s \n", dn)
+	return ac.endpointGroups[dn].managed["consumed"], nil
+}
+
+func (ac *ApicClientMocks) GetManagedProvidedContracts(dn string) ([]string, error) {
+	fmt.Printf("Getting contracts of %s \n", dn)
+	return ac.endpointGroups[dn].managed["provided"], nil
+}
+
+func (ac *ApicClientMocks) RemoveConsumedContract(dn, contract string) error {
+	fmt.Printf("%s removing consumed contract %s\n", dn, contract)
+	ac.endpointGroups[dn].contracts["consumed"] = lo.Without(ac.endpointGroups[dn].contracts["consumed"], contract)
+	ac.endpointGroups[dn].managed["consumed"] = lo.Without(ac.endpointGroups[dn].managed["consumed"], contract)
+	return nil
+}
+
+func (ac *ApicClientMocks) RemoveProvidedContract(dn, contract string) error {
+	fmt.Printf("%s removing provided contract %s\n", dn, contract)
+	ac.endpointGroups[dn].contracts["provided"] = lo.Without(ac.endpointGroups[dn].contracts["provided"], contract)
+	ac.endpointGroups[dn].managed["provided"] = lo.Without(ac.endpointGroups[dn].managed["provided"], contract)
+	return nil
+}
+
+// ConsumeUnmanagedContract adds a consumed contract the same way an APIC admin
+// would do by hand, without the operator annotation.
+func (ac *ApicClientMocks) ConsumeUnmanagedContract(epg, app, tenant, contract string) {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("EPG %s consuming unmanaged contract %s\n", dn, contract)
+	ac.endpointGroups[dn].contracts["consumed"] = append(ac.endpointGroups[dn].contracts["consumed"], contract)
+}
+
+// SetBridgeDomain changes the bridge domain of an EPG the way an APIC admin
+// would do by hand.
+func (ac *ApicClientMocks) SetBridgeDomain(epg, app, tenant, bd string) {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("EPG %s changing BD to %s by hand\n", dn, bd)
+	endpointGroup := ac.endpointGroups[dn]
+	endpointGroup.Bd = bd
+	ac.endpointGroups[dn] = endpointGroup
+}
+
+func (ac *ApicClientMocks) GetEpgTag(epg, app, tenant, key string) (string, error) {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Reading tag %s of EPG %s\n", key, dn)
+	return ac.endpointGroups[dn].tags[key], nil
+}
+
+func (ac *ApicClientMocks) SetEpgTag(epg, app, tenant, key, value string) error {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Setting tag %s=%s on EPG %s\n", key, value, dn)
+	ac.endpointGroups[dn].tags[key] = value
+	return nil
+}
+
+func (ac *ApicClientMocks) RemoveEpgTag(epg, app, tenant, key string) error {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Removing tag %s from EPG %s\n", key, dn)
+	delete(ac.endpointGroups[dn].tags, key)
+	return nil
+}
+
+func (ac *ApicClientMocks) EnsureApplicationProfile(app, tenant string) (bool, error) {
+	dn := ApplicationProfileDn(app, tenant)
+	fmt.Printf("Ensuring application profile %s\n", dn)
+	if ac.applicationProfiles[dn] {
+		return false, nil
+	}
+	ac.applicationProfiles[dn] = true
+	return true, nil
+}
+
+func (ac *ApicClientMocks) EnsureBridgeDomain(bd, tenant, vrf string, subnets []string) (bool, error) {
+	dn := BridgeDomainDn(bd, tenant)
+	fmt.Printf("Ensuring bridge domain %s\n", dn)
+	bridgeDomain, exists := ac.bridgeDomains[dn]
+	if !exists {
+		bridgeDomain.Vrf = vrf
+	}
+	bridgeDomain.Subnets = lo.Union(bridgeDomain.Subnets, subnets)
+	ac.bridgeDomains[dn] = bridgeDomain
+	return !exists, nil
+}
+
+// GetBridgeDomain returns the VRF and subnets of a bridge domain created with
+// EnsureBridgeDomain, only used in tests.
+func (ac *ApicClientMocks) GetBridgeDomain(bd, tenant string) (string, []string, bool) {
+	bridgeDomain, exists := ac.bridgeDomains[BridgeDomainDn(bd, tenant)]
+	return bridgeDomain.Vrf, bridgeDomain.Subnets, exists
+}
+
+func (ac *ApicClientMocks) EsgExists(name, app, tenant string) (bool, error) {
+	_, exists := ac.securityGroups[EsgDn(name, app, tenant)]
+	return exists, nil
+}
+
+func (ac *ApicClientMocks) CreateEsg(name, app, tenant, vrf string) error {
+	dn := EsgDn(name, app, tenant)
+	fmt.Printf("Creating ESG %s\n", dn)
+	ac.securityGroups[dn] = securityGroup{Vrf: vrf}
+	// The contracts of the ESG are kept like the ones of an EPG.
+	ac.endpointGroups[dn] = endpointGroup{name: name, app: app, tenant: tenant, contracts: map[string][]string{}, managed: map[string][]string{}, tags: map[string]string{}}
+	return nil
+}
+
+func (ac *ApicClientMocks) DeleteEsg(name, app, tenant string) error {
+	dn := EsgDn(name, app, tenant)
+	fmt.Printf("Deleting ESG %s\n", dn)
+	delete(ac.securityGroups, dn)
+	delete(ac.endpointGroups, dn)
+	return nil
+}
+
+func (ac *ApicClientMocks) AddEpgSelector(esg, app, tenant, epgDn string) error {
+	dn := EsgDn(esg, app, tenant)
+	securityGroup := ac.securityGroups[dn]
+	securityGroup.EpgSelectors = lo.Union(securityGroup.EpgSelectors, []string{epgDn})
+	ac.securityGroups[dn] = securityGroup
+	return nil
+}
+
+func (ac *ApicClientMocks) RemoveEpgSelector(esg, app, tenant, epgDn string) error {
+	dn := EsgDn(esg, app, tenant)
+	securityGroup := ac.securityGroups[dn]
+	securityGroup.EpgSelectors = lo.Without(securityGroup.EpgSelectors, epgDn)
+	ac.securityGroups[dn] = securityGroup
+	return nil
+}
+
+func (ac *ApicClientMocks) GetManagedTagSelectors(esg, app, tenant string) ([]TagSelector, error) {
+	return ac.securityGroups[EsgDn(esg, app, tenant)].TagSelectors, nil
+}
+
+func (ac *ApicClientMocks) AddTagSelector(esg, app, tenant string, selector TagSelector) error {
+	dn := EsgDn(esg, app, tenant)
+	securityGroup := ac.securityGroups[dn]
+	securityGroup.TagSelectors = lo.Union(securityGroup.TagSelectors, []TagSelector{selector})
+	ac.securityGroups[dn] = securityGroup
+	return nil
+}
+
+func (ac *ApicClientMocks) RemoveTagSelector(esg, app, tenant string, selector TagSelector) error {
+	dn := EsgDn(esg, app, tenant)
+	securityGroup := ac.securityGroups[dn]
+	securityGroup.TagSelectors = lo.Without(securityGroup.TagSelectors, selector)
+	ac.securityGroups[dn] = securityGroup
+	return nil
+}
+
+// CreateExistingEsg creates an ESG the way an APIC admin would do by hand,
+// only used in tests.
+func (ac *ApicClientMocks) CreateExistingEsg(name, app, tenant, vrf string) {
+	_ = ac.CreateEsg(name, app, tenant, vrf)
+}
+
+// GetEsg returns the VRF and selectors of an ESG and whether it exists, only
+// used in tests.
+func (ac *ApicClientMocks) GetEsg(name, app, tenant string) (securityGroup, bool) {
+	securityGroup, exists := ac.securityGroups[EsgDn(name, app, tenant)]
+	return securityGroup, exists
+}
+
+func (ac *ApicClientMocks) ApplyFilter(tenant string, filter Filter) error {
+	dn := FilterDn(filter.Name, tenant)
+	fmt.Printf("Applying filter %s\n", dn)
+	ac.filters[dn] = filter
+	return nil
+}
+
+func (ac *ApicClientMocks) DeleteFilter(name, tenant string) error {
+	dn := FilterDn(name, tenant)
+	fmt.Printf("Deleting filter %s\n", dn)
+	delete(ac.filters, dn)
+	delete(ac.tags, dn)
+	return nil
+}
+
+func (ac *ApicClientMocks) ApplyContract(tenant string, contract Contract) error {
+	dn := ContractDn(contract.Name, tenant)
+	fmt.Printf("Applying contract %s\n", dn)
+	ac.contracts[dn] = contract
+	return nil
+}
+
+func (ac *ApicClientMocks) DeleteContract(name, tenant string) error {
+	dn := ContractDn(name, tenant)
+	fmt.Printf("Deleting contract %s\n", dn)
+	delete(ac.contracts, dn)
+	delete(ac.tags, dn)
+	return nil
+}
+
+// ContractExists treats every contract as existing unless it is marked
+// missing with SetContractMissing, so tests do not have to create the
+// contracts they bind.
+func (ac *ApicClientMocks) ContractExists(tenant, name string) (bool, error) {
+	fmt.Printf("Looking up contract %s from tenant %s\n", name, tenant)
+	return !ac.missingContracts[name], nil
+}
+
+// SetContractMissing marks the contract as missing in every tenant, only
+// used in tests.
+func (ac *ApicClientMocks) SetContractMissing(name string, missing bool) {
+	ac.missingContracts[name] = missing
+}
+
+func (ac *ApicClientMocks) ReadOwnership(dn string) (Ownership, error) {
+	_, contractExists := ac.contracts[dn]
+	_, filterExists := ac.filters[dn]
+	exists := contractExists || filterExists
+	return Ownership{Exists: exists, Managed: exists && !ac.unmanaged[dn], Owner: ac.tags[dn][OwnerTagKey]}, nil
+}
+
+func (ac *ApicClientMocks) SetTag(dn, key, value string) error {
+	fmt.Printf("Setting tag %s=%s on %s\n", key, value, dn)
+	if ac.tags[dn] == nil {
+		ac.tags[dn] = map[string]string{}
+	}
+	ac.tags[dn][key] = value
+	return nil
+}
+
+// GetTag returns the value of a tag set with SetTag, only used in tests.
+func (ac *ApicClientMocks) GetTag(dn, key string) string {
+	return ac.tags[dn][key]
+}
+
+// CreateUnmanagedContract creates a contract the way an APIC admin would do
+// by hand, without the ManagedAnnotation.
+func (ac *ApicClientMocks) CreateUnmanagedContract(name, tenant string) {
+	dn := ContractDn(name, tenant)
+	fmt.Printf("Creating contract %s by hand\n", dn)
+	ac.contracts[dn] = Contract{Name: name}
+	ac.unmanaged[dn] = true
+}
+
+// GetContract returns a contract created with ApplyContract and whether it
+// exists, only used in tests.
+func (ac *ApicClientMocks) GetContract(name, tenant string) (Contract, bool) {
+	contract, exists := ac.contracts[ContractDn(name, tenant)]
+	return contract, exists
+}
+
+// GetFilter returns a filter created with ApplyFilter and whether it exists,
+// only used in tests.
+func (ac *ApicClientMocks) GetFilter(name, tenant string) (Filter, bool) {
+	filter, exists := ac.filters[FilterDn(name, tenant)]
+	return filter, exists
+}
+
+// CreateUntaggedEpg creates an EPG the way releases of the operator did
+// before they tagged the EPGs they own.
+func (ac *ApicClientMocks) CreateUntaggedEpg(epg, app, tenant, vmmType string) {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Creating untagged EPG %s\n", dn)
+	ac.endpointGroups[dn] = endpointGroup{name: epg, app: app, tenant: tenant, annotation: EpgAnnotation(vmmType), descr: EpgDescription, VmmType: vmmType,
+		contracts: map[string][]string{}, managed: map[string][]string{}, tags: map[string]string{}}
+}
+
+// CreateUnownedEpg creates an EPG the way an APIC admin would do by hand,
+// without the owner tag.
+func (ac *ApicClientMocks) CreateUnownedEpg(epg, app, tenant string) {
+	dn := fmt.Sprintf("uni/tn-%s/ap-%s/epg-%s", tenant, app, epg)
+	fmt.Printf("Creating EPG %s by hand\n", dn)
+	ac.endpointGroups[dn] = endpointGroup{name: epg, app: app, tenant: tenant, contracts: map[string][]string{}, managed: map[string][]string{}, tags: map[string]string{}}
+}
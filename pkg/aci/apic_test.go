@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aci
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeApic answers the login and the topSystem query of an APIC. Only the
+// passwords it knows are accepted.
+type fakeApic struct {
+	lock      sync.Mutex
+	passwords map[string]bool
+}
+
+func (f *fakeApic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api/aaaLogin.json":
+		login := struct {
+			AaaUser struct {
+				Attributes struct {
+					Pwd string `json:"pwd"`
+				} `json:"attributes"`
+			} `json:"aaaUser"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&login)
+		f.lock.Lock()
+		accepted := f.passwords[login.AaaUser.Attributes.Pwd]
+		f.lock.Unlock()
+		if !accepted {
+			fmt.Fprint(w, `{"totalCount":"1","imdata":[{"error":{"attributes":{"code":"403","text":""}}}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"totalCount":"1","imdata":[{"aaaLogin":{"attributes":{"token":"token","creationTime":"%d","refreshTimeoutSeconds":"600"}}}]}`, time.Now().Unix())
+	case strings.HasSuffix(r.URL.Path, "/topSystem.json"):
+		fmt.Fprint(w, `{"totalCount":"1","imdata":[{"topSystem":{"attributes":{"dn":"topology/pod-1/node-1/sys","name":"apic1"}}}]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var _ = Describe("ApicClient", func() {
+	It("Should keep the current credentials when the new ones are rejected", func() {
+		apic := &fakeApic{passwords: map[string]bool{"old": true}}
+		server := httptest.NewTLSServer(apic)
+		defer server.Close()
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+		ac, err := NewClient([]string{server.Listener.Addr().String()},
+			Credentials{Username: "admin", Password: "old"}, TLSOptions{CABundle: caBundle})
+		Expect(err).ShouldNot(HaveOccurred())
+		ac.retries = 0
+
+		err = ac.SetCredentials(Credentials{Username: "admin", Password: "new"})
+		Expect(err).Should(MatchError(ErrAuthFailed))
+		Expect(ac.credentials.Password).Should(Equal("old"))
+		Expect(ac.CheckConnection()).Should(Succeed())
+
+		apic.lock.Lock()
+		apic.passwords["new"] = true
+		apic.lock.Unlock()
+		Expect(ac.SetCredentials(Credentials{Username: "admin", Password: "new"})).Should(Succeed())
+		Expect(ac.credentials.Password).Should(Equal("new"))
+		Expect(ac.CheckConnection()).Should(Succeed())
+	})
+})
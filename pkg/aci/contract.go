@@ -0,0 +1,262 @@
+package aci
+
+import (
+	"fmt"
+	"regexp"
+
+	aciclient "github.com/ciscoecosystem/aci-go-client/client"
+	"github.com/ciscoecosystem/aci-go-client/models"
+	"github.com/samber/lo"
+)
+
+// Contract is a vzBrCP with its subjects.
+type Contract struct {
+	Name     string
+	Scope    string
+	Subjects []ContractSubject
+}
+
+// ContractSubject is a vzSubj and the names of the filters it applies.
+type ContractSubject struct {
+	Name               string
+	ReverseFilterPorts bool
+	Filters            []string
+}
+
+// Filter is a vzFilter with its entries.
+type Filter struct {
+	Name    string
+	Entries []FilterEntry
+}
+
+// FilterEntry is a vzEntry matching IP traffic by protocol and destination
+// port range. Empty ports match all ports.
+type FilterEntry struct {
+	Name     string
+	Protocol string
+	FromPort string
+	ToPort   string
+}
+
+// ContractDn returns the distinguished name of a contract on the APIC.
+func ContractDn(name, tenant string) string {
+	return fmt.Sprintf("uni/tn-%s/brc-%s", tenant, name)
+}
+
+// ParseContractDn returns the name and tenant of the contract with the
+// distinguished name.
+func ParseContractDn(dn string) (string, string, error) {
+	match := contractDnPattern.FindStringSubmatch(dn)
+	if match == nil {
+		return "", "", fmt.Errorf("invalid contract distinguished name %q", dn)
+	}
+	return match[2], match[1], nil
+}
+
+var contractDnPattern = regexp.MustCompile(`^uni/tn-([^/]+)/brc-([^/]+)$`)
+
+// FilterDn returns the distinguished name of a filter on the APIC.
+func FilterDn(name, tenant string) string {
+	return fmt.Sprintf("uni/tn-%s/flt-%s", tenant, name)
+}
+
+// ApplyFilter creates or updates the filter in the tenant, entries that are
+// no longer in the filter are deleted.
+func (ac *ApicClient) ApplyFilter(tenant string, filter Filter) error {
+	return ac.do("ApplyFilter", func(c *aciclient.Client) error {
+		filterDn := FilterDn(filter.Name, tenant)
+		vzFilterAttr := models.FilterAttributes{}
+		vzFilterAttr.Annotation = ManagedAnnotation
+		vzFilter := models.NewFilter(fmt.Sprintf("flt-%s", filter.Name), fmt.Sprintf("uni/tn-%s", tenant), "created by kubernetes operator", vzFilterAttr)
+		err := c.Save(vzFilter)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range filter.Entries {
+			vzEntryAttr := models.FilterEntryAttributes{}
+			vzEntryAttr.Annotation = ManagedAnnotation
+			vzEntryAttr.EtherT = "ip"
+			vzEntryAttr.Prot = entry.Protocol
+			vzEntryAttr.DFromPort = lo.Ternary(entry.FromPort != "", entry.FromPort, "unspecified")
+			vzEntryAttr.DToPort = lo.Ternary(entry.ToPort != "", entry.ToPort, "unspecified")
+			vzEntry := models.NewFilterEntry(fmt.Sprintf("e-%s", entry.Name), filterDn, "", vzEntryAttr)
+			err = c.Save(vzEntry)
+			if err != nil {
+				return err
+			}
+		}
+
+		entries, err := childNames(c, filterDn, models.VzentryClassName)
+		if err != nil {
+			return err
+		}
+		stale, _ := lo.Difference(entries, lo.Map(filter.Entries, func(entry FilterEntry, _ int) string { return entry.Name }))
+		for _, entry := range stale {
+			err = c.DeleteFilterEntry(entry, filter.Name, tenant)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ac *ApicClient) DeleteFilter(name, tenant string) error {
+	return ac.do("DeleteFilter", func(c *aciclient.Client) error {
+		return c.DeleteFilter(name, tenant)
+	})
+}
+
+// ApplyContract creates or updates the contract in the tenant. Subjects and
+// filter relations that are no longer in the contract are deleted, the
+// filters must already exist.
+func (ac *ApicClient) ApplyContract(tenant string, contract Contract) error {
+	return ac.do("ApplyContract", func(c *aciclient.Client) error {
+		contractDn := ContractDn(contract.Name, tenant)
+		vzBrCPAttr := models.ContractAttributes{}
+		vzBrCPAttr.Annotation = ManagedAnnotation
+		vzBrCPAttr.Scope = contract.Scope
+		vzBrCP := models.NewContract(fmt.Sprintf("brc-%s", contract.Name), fmt.Sprintf("uni/tn-%s", tenant), "created by kubernetes operator", vzBrCPAttr)
+		err := c.Save(vzBrCP)
+		if err != nil {
+			return err
+		}
+
+		for _, subject := range contract.Subjects {
+			subjectDn := fmt.Sprintf("%s/subj-%s", contractDn, subject.Name)
+			vzSubjAttr := models.ContractSubjectAttributes{}
+			vzSubjAttr.Annotation = ManagedAnnotation
+			vzSubjAttr.RevFltPorts = lo.Ternary(subject.ReverseFilterPorts, "yes", "no")
+			vzSubj := models.NewContractSubject(fmt.Sprintf("subj-%s", subject.Name), contractDn, "", vzSubjAttr)
+			err = c.Save(vzSubj)
+			if err != nil {
+				return err
+			}
+
+			for _, filter := range subject.Filters {
+				err = c.CreateRelationvzRsSubjFiltAttFromContractSubject(subjectDn, filter)
+				if err != nil {
+					return err
+				}
+			}
+			filters, err := childValues(c, subjectDn, "vzRsSubjFiltAtt", "tnVzFilterName")
+			if err != nil {
+				return err
+			}
+			stale, _ := lo.Difference(filters, subject.Filters)
+			for _, filter := range stale {
+				err = c.DeleteRelationvzRsSubjFiltAttFromContractSubject(subjectDn, filter)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		subjects, err := childNames(c, contractDn, models.VzsubjClassName)
+		if err != nil {
+			return err
+		}
+		stale, _ := lo.Difference(subjects, lo.Map(contract.Subjects, func(subject ContractSubject, _ int) string { return subject.Name }))
+		for _, subject := range stale {
+			err = c.DeleteContractSubject(subject, contract.Name, tenant)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ac *ApicClient) DeleteContract(name, tenant string) error {
+	return ac.do("DeleteContract", func(c *aciclient.Client) error {
+		return c.DeleteContract(name, tenant)
+	})
+}
+
+// CommonTenant is the tenant the APIC looks in for a contract that does not
+// exist in the tenant of the EPG.
+const CommonTenant = "common"
+
+// ContractExists tells if the contract name resolves from the tenant, either
+// to a contract in the tenant or in common.
+func (ac *ApicClient) ContractExists(tenant, name string) (bool, error) {
+	exists := false
+	err := ac.do("ContractExists", func(c *aciclient.Client) error {
+		var err error
+		exists, err = objectExists(c, ContractDn(name, tenant))
+		if err != nil || exists || tenant == CommonTenant {
+			return err
+		}
+		exists, err = objectExists(c, ContractDn(name, CommonTenant))
+		return err
+	})
+	return exists, err
+}
+
+// Ownership tells who created an object on the APIC.
+type Ownership struct {
+	Exists bool
+	// Managed is set when the object carries the ManagedAnnotation, that is
+	// it was created by the operator.
+	Managed bool
+	// Owner is the value of the tag with the OwnerTagKey, empty without tag.
+	Owner string
+}
+
+// ReadOwnership returns the ownership of the object at the dn.
+func (ac *ApicClient) ReadOwnership(dn string) (Ownership, error) {
+	ownership := Ownership{}
+	err := ac.do("ReadOwnership", func(c *aciclient.Client) error {
+		cont, err := c.Get(dn)
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		objects, err := cont.S("imdata").Index(0).ChildrenMap()
+		if err != nil {
+			return nil
+		}
+		for _, object := range objects {
+			ownership.Exists = true
+			ownership.Managed = models.G(object.S("attributes"), "annotation") == ManagedAnnotation
+		}
+		if !ownership.Exists {
+			return nil
+		}
+		tag, err := c.ReadAnnotation(OwnerTagKey, dn)
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		ownership.Owner = tag.Value
+		return nil
+	})
+	return ownership, err
+}
+
+// childNames returns the names of the children of the class under the dn.
+func childNames(c *aciclient.Client, dn, className string) ([]string, error) {
+	return childValues(c, dn, className, "name")
+}
+
+// childValues returns the attribute of the children of the class under the
+// dn.
+func childValues(c *aciclient.Client, dn, className, attribute string) ([]string, error) {
+	values := []string{}
+	cont, err := c.GetViaURL(fmt.Sprintf("/api/node/class/%s/%s.json", dn, className))
+	if err != nil {
+		if isNotFound(err) {
+			return values, nil
+		}
+		return nil, err
+	}
+	for _, child := range models.ListFromContainer(cont, className) {
+		values = append(values, models.G(child, attribute))
+	}
+	return values, nil
+}
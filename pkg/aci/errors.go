@@ -0,0 +1,97 @@
+package aci
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Classes of errors returned by the APIC. Errors returned by ApicClient match
+// one of them with errors.Is.
+var (
+	// ErrNotFound is returned when the object does not exist on the APIC.
+	ErrNotFound = errors.New("not found")
+	// ErrAuthFailed is returned when the APIC rejects the credentials.
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrThrottled is returned when the APIC rate limits the operator.
+	ErrThrottled = errors.New("throttled")
+	// ErrInvalidConfig is returned when the APIC rejects the configuration,
+	// retrying will not help until the configuration is changed.
+	ErrInvalidConfig = errors.New("invalid configuration")
+	// ErrTransient is returned when the APIC cannot be reached or fails with
+	// a server error.
+	ErrTransient = errors.New("transient error")
+	// ErrUnknown is returned for errors that match no other class. They are
+	// not assumed to be caused by the configuration and are retried.
+	ErrUnknown = errors.New("unknown error")
+)
+
+// ApicError is an error returned by an operation on the APIC.
+type ApicError struct {
+	Operation string
+	Class     error
+	Err       error
+}
+
+func (e *ApicError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Operation, e.Class, e.Err)
+}
+
+func (e *ApicError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ApicError) Is(target error) bool {
+	return target == e.Class
+}
+
+// classify returns the class of an error returned by the aci-go-client, which
+// only reports errors as text. Only the errors the APIC returns for invalid
+// objects and properties are configuration errors.
+func classify(err error) error {
+	msg := err.Error()
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, "may not exists"):
+		return ErrNotFound
+	case strings.Contains(msg, "Unable to authenticate"),
+		strings.Contains(msg, "Invalid Username or Password"),
+		strings.Contains(msg, "Token was invalid"):
+		return ErrAuthFailed
+	case strings.Contains(msg, "throttled"):
+		return ErrThrottled
+	case strings.Contains(msg, "Failed to connect to APIC"),
+		strings.Contains(msg, "Failed to parse JSON response"),
+		strings.Contains(msg, "Empty response"):
+		return ErrTransient
+	case strings.Contains(lower, "invalid"),
+		strings.Contains(lower, "unknown property"),
+		strings.Contains(lower, "unknown managed object class"),
+		strings.Contains(lower, "validation failed"):
+		return ErrInvalidConfig
+	default:
+		return ErrUnknown
+	}
+}
+
+func isNotFound(err error) bool {
+	return err != nil && classify(err) == ErrNotFound
+}
+
+// retryable reports whether an operation failing with err may succeed when
+// retried without changes.
+func retryable(err error) bool {
+	return errors.Is(err, ErrTransient) || errors.Is(err, ErrThrottled)
+}
+
+// backoff returns the delay before the next attempt, doubling for every
+// attempt up to maxDelay with jitter so that retries do not arrive in bursts.
+func backoff(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
+	delay := baseDelay << attempt
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
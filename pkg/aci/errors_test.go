@@ -0,0 +1,47 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aci
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("APIC error classes", func() {
+	It("Should classify the errors of the aci-go-client", func() {
+		for msg, class := range map[string]error{
+			"Error retrieving Object: Object may not exists":            ErrNotFound,
+			"Unable to authenticate. Please check your credentials":     ErrAuthFailed,
+			"APIC throttled the request: 429 Too Many Requests":         ErrThrottled,
+			"Failed to connect to APIC. Verify that you are connecting": ErrTransient,
+			"unknown property value foo, name scope, class vzBrCP":      ErrInvalidConfig,
+			"Invalid request, bad DN uni/tn-optest/ap-":                 ErrInvalidConfig,
+			"Object is locked by another configuration":                 ErrUnknown,
+		} {
+			Expect(classify(errors.New(msg))).Should(Equal(class), msg)
+		}
+	})
+
+	It("Should only retry transient and throttled errors in the client", func() {
+		Expect(retryable(&ApicError{Class: ErrTransient})).Should(BeTrue())
+		Expect(retryable(&ApicError{Class: ErrThrottled})).Should(BeTrue())
+		Expect(retryable(&ApicError{Class: ErrUnknown})).Should(BeFalse())
+		Expect(retryable(&ApicError{Class: ErrInvalidConfig})).Should(BeFalse())
+	})
+})
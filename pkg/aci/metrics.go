@@ -0,0 +1,68 @@
+package aci
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	apicRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "epg_operator_apic_requests_total",
+			Help: "Number of operations sent to the APIC.",
+		},
+		[]string{"operation", "host"},
+	)
+	apicRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "epg_operator_apic_request_errors_total",
+			Help: "Number of operations sent to the APIC that returned an error.",
+		},
+		[]string{"operation", "host"},
+	)
+	apicRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "epg_operator_apic_request_duration_seconds",
+			Help:    "Time taken by operations sent to the APIC.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation", "host"},
+	)
+	apicActiveHost = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "epg_operator_apic_active_host",
+			Help: "Set to 1 for the APIC host operations are sent to, 0 for the other members of the cluster.",
+		},
+		[]string{"host"},
+	)
+	apicFailovers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "epg_operator_apic_failovers_total",
+			Help: "Number of times the operator switched to another APIC host.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(apicRequests, apicRequestErrors, apicRequestDuration, apicActiveHost, apicFailovers)
+}
+
+func observeRequest(operation, host string, start time.Time, err error) {
+	apicRequests.WithLabelValues(operation, host).Inc()
+	apicRequestDuration.WithLabelValues(operation, host).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apicRequestErrors.WithLabelValues(operation, host).Inc()
+	}
+}
+
+func setActiveHost(hosts []string, active int) {
+	for i, host := range hosts {
+		if i == active {
+			apicActiveHost.WithLabelValues(host).Set(1)
+		} else {
+			apicActiveHost.WithLabelValues(host).Set(0)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package aci
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MaxNameLength is the longest name the APIC accepts for EPGs and contracts.
+const MaxNameLength = 64
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+$`)
+
+// ValidateName checks a name against the APIC naming rules, at most 64
+// characters of letters, digits and the characters _ . : -
+func ValidateName(name string) error {
+	if len(name) == 0 || len(name) > MaxNameLength {
+		return fmt.Errorf("name %q must be between 1 and %d characters", name, MaxNameLength)
+	}
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("name %q may only contain letters, digits and the characters _ . : -", name)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package aci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// TLSOptions configure how the certificate of the APIC is verified.
+type TLSOptions struct {
+	// CABundle holds the PEM encoded certificates trusted for the APIC, the
+	// system roots are used when it is empty.
+	CABundle []byte
+	// ServerName overrides the name the certificate is verified against,
+	// for APIC hosts given as IP addresses.
+	ServerName string
+	// InsecureSkipVerify accepts any certificate from the APIC.
+	InsecureSkipVerify bool
+}
+
+// tlsConfig returns the TLS config for the connections to the APIC.
+func (o TLSOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if len(o.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(o.CABundle) {
+			return nil, fmt.Errorf("the APIC CA bundle has no PEM encoded certificates")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
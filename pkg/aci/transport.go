@@ -0,0 +1,29 @@
+package aci
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// failoverTransport turns 5xx and 429 responses into errors. The aci-go-client
+// reports them as "Failed to connect to APIC", so that server errors and
+// unreachable hosts both make the ApicClient fail over to the next APIC.
+type failoverTransport struct {
+	next http.RoundTripper
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, fmt.Errorf("APIC throttled the request: %s", resp.Status)
+	}
+	if resp.StatusCode >= 500 && resp.StatusCode <= 504 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("APIC returned %s", resp.Status)
+	}
+	return resp, nil
+}